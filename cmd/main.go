@@ -1,41 +1,80 @@
 package main
 
 import (
+	"context"
 	"github.com/cyclegen-community/tdx-go/config"
 	"github.com/cyclegen-community/tdx-go/core"
 	"github.com/cyclegen-community/tdx-go/proto"
 	"github.com/cyclegen-community/tdx-go/proto/v1"
+	"github.com/cyclegen-community/tdx-go/utils/logger"
 	"log"
+	"os"
+	"path/filepath"
 )
 
 func init() {
 	log.SetFlags(log.Lshortfile | log.Ldate)
+	config.SetLogger(logger.New(nil, logger.LevelInfo))
 }
+
+// 记分板保存在用户缓存目录中，下次启动时无需重新测速
+func scoreboardPath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "tdx-go", "scoreboard.json")
+}
+
 func main() {
-	quotesSrv := config.GetBestStockQuotesServer()
+	board, err := core.LoadScoreboard(scoreboardPath())
+	if err != nil {
+		log.Println("读取记分板失败: ", err)
+		board = core.NewScoreboard()
+	}
+	srvs, err := config.LoadDefaultStockQuotesServer()
+	if err != nil {
+		log.Fatal(err)
+	}
+	quotesSrv, err := srvs.Select(context.Background(), board)
+	if err != nil {
+		log.Fatal(err)
+	}
 	//quotesSrvAddr := "106.120.74.86:7711" // quotesSrv.Addr()
 	log.Println("正在连接到最优行情服务器: ", quotesSrv.Addr())
-	T(quotesSrv.IP, quotesSrv.Port)
-	//T("106.120.74.86", 7709)
+	T(quotesSrv, board)
+	//T(config.Server{IP: "106.120.74.86", Port: 7709}, board)
+	if err := board.Save(scoreboardPath()); err != nil {
+		log.Println("保存记分板失败: ", err)
+	}
 }
-func T(ip string, port int) {
-	cli := core.NewClient(ip, port)
+func T(srv config.Server, board *core.Scoreboard) {
+	dialer, err := srv.Dialer()
+	if err != nil {
+		log.Fatal(err)
+	}
+	cli, err := core.Dial(context.Background(), srv.Addr(),
+		core.WithProxy(dialer), core.WithScoreboard(board), core.WithLogger(logger.New(nil, logger.LevelInfo)))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer cli.Close()
 
-	// CMD信令 1
-	testProto(cli, func() (req proto.Marshaler, resp proto.Unmarshaler, err error) {
-		req, resp, err = v1.NewSetupCmd1()
-		return
-	})
-	// CMD信令 2
-	testProto(cli, func() (req proto.Marshaler, resp proto.Unmarshaler, err error) {
-		req, resp, err = v1.NewSetupCmd2()
-		return
-	})
-	// CMD信令 3
-	testProto(cli, func() (req proto.Marshaler, resp proto.Unmarshaler, err error) {
-		req, resp, err = v1.NewSetupCmd3()
+	// 连接建立后客户端已自动完成CMD信令1/2/3握手
+	// 指定指令名称与JSON参数时只执行该指令，如: GetSecurityList '{"market": 1, "start": 0}'
+	if len(os.Args) > 1 {
+		var params []byte
+		if len(os.Args) > 2 {
+			params = []byte(os.Args[2])
+		}
+		factory, err := proto.DefaultRegistry.Factory(os.Args[1], params)
+		if err != nil {
+			log.Fatal(err)
+		}
+		testProto(cli, factory)
 		return
-	})
+	}
+
 	// 查询股票数量
 	testProto(cli, func() (req proto.Marshaler, resp proto.Unmarshaler, err error) {
 		req, resp, err = v1.NewGetSecurityCount(v1.MarketShangHai)
@@ -46,10 +85,13 @@ func T(ip string, port int) {
 		req, resp, err = v1.NewGetSecurityList(v1.MarketShangHai, 255)
 		return
 	})
-	//testProto(cli, func() (req proto.Marshaler, resp proto.Unmarshaler, err error) {
-	//	req, resp, err = v1.NewGetSecurityQuotes()
-	//	return
-	//})
+	testProto(cli, func() (req proto.Marshaler, resp proto.Unmarshaler, err error) {
+		req, resp, err = v1.NewGetSecurityQuotes([]v1.Security{
+			{Market: v1.MarketShenZhen, Code: "000001"},
+			{Market: v1.MarketShangHai, Code: "600300"},
+		})
+		return
+	})
 }
 
 func testProto(cli *core.Client, factory proto.Factory) {
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// tempDir 创建测试用的临时目录，测试结束后删除
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "tdx-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func TestDefaultStockQuotesServer(t *testing.T) {
+	// 内置列表需与stock_ip.json保持一致
+	srvs, err := LoadStockQuotesServer("stock_ip.json")
+	assert.NoError(t, err)
+	assert.Equal(t, srvs, DefaultStockQuotesServer())
+
+	// 返回副本，修改不影响内置列表
+	DefaultStockQuotesServer()[0].IP = "127.0.0.1"
+	assert.Equal(t, srvs[0], DefaultStockQuotesServer()[0])
+}
+
+func TestReadStockQuotesServer(t *testing.T) {
+	srvs, err := ReadStockQuotesServer(strings.NewReader(`[{"name": "a", "ip": "127.0.0.1", "port": 7709, "proxy": "socks5://127.0.0.1:1080"}]`))
+	assert.NoError(t, err)
+	assert.Equal(t, StockQuotesServer{{Name: "a", IP: "127.0.0.1", Port: 7709, Proxy: "socks5://127.0.0.1:1080"}}, srvs)
+
+	for _, raw := range []string{
+		``,
+		`[]`,
+		`{"ip": "127.0.0.1"}`,
+		`[{"ip": "127.0.0.1", "ort": 7709}]`,
+		`[{"ip": "127.0.0.1"}]`,
+		`[{"port": 7709}]`,
+		`[{"ip": "127.0.0.1", "port": 7709, "proxy": "ftp://127.0.0.1"}]`,
+	} {
+		_, err := ReadStockQuotesServer(strings.NewReader(raw))
+		assert.True(t, errors.Is(err, ErrInvalidConfig), raw)
+	}
+}
+
+func TestLoadDefaultStockQuotesServer(t *testing.T) {
+	old, set := os.LookupEnv(EnvStockQuotesServer)
+	defer func() {
+		if set {
+			os.Setenv(EnvStockQuotesServer, old)
+		} else {
+			os.Unsetenv(EnvStockQuotesServer)
+		}
+	}()
+
+	os.Unsetenv(EnvStockQuotesServer)
+	srvs, err := LoadDefaultStockQuotesServer()
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultStockQuotesServer(), srvs)
+	assert.Equal(t, srvs, GetStockQuotesServer())
+
+	path := filepath.Join(tempDir(t), "servers.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`[{"ip": "127.0.0.1", "port": 7709}]`), 0644))
+	os.Setenv(EnvStockQuotesServer, path)
+	srvs, err = LoadDefaultStockQuotesServer()
+	assert.NoError(t, err)
+	assert.Equal(t, StockQuotesServer{{IP: "127.0.0.1", Port: 7709}}, srvs)
+
+	// 直接使用通达信客户端的connect.cfg
+	path = filepath.Join(tempDir(t), "connect.cfg")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(connectCfg), 0644))
+	os.Setenv(EnvStockQuotesServer, path)
+	srvs, err = LoadDefaultStockQuotesServer()
+	assert.NoError(t, err)
+	assert.Len(t, srvs, 2)
+
+	os.Setenv(EnvStockQuotesServer, filepath.Join(tempDir(t), "missing.json"))
+	_, err = LoadDefaultStockQuotesServer()
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/cyclegen-community/tdx-go/utils/parse"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// connect.cfg中的线路分组
+const (
+	// 标准行情(沪深A股等)线路
+	connectCfgStockSection = "hqhost"
+	// 扩展行情(期货、港股等)线路
+	connectCfgExSection = "dshost"
+)
+
+// ConnectConfig 通达信客户端connect.cfg中的行情线路
+type ConnectConfig struct {
+	// StockQuotes 标准行情线路，对应[HQHOST]
+	StockQuotes StockQuotesServer
+	// ExQuotes 扩展行情线路，对应[DSHOST]
+	ExQuotes StockQuotesServer
+}
+
+// ReadConnectCfg 从r中读取通达信客户端的connect.cfg
+// 文件为GBK编码的INI格式，每个分组中以HostName01、IPAddress01、Port01等带序号的键描述一条线路
+// 已转存为UTF-8的文件同样可以读取；地址或端口无效的线路被忽略，文件中没有任何标准行情线路时返回ErrInvalidConfig
+func ReadConnectCfg(r io.Reader) (*ConnectConfig, error) {
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	raw = bytes.TrimPrefix(raw, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(raw) {
+		if raw, err = parse.DecodeGBK(raw); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+		}
+	}
+	sections, err := parseConnectCfg(raw)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &ConnectConfig{
+		StockQuotes: connectCfgServers(sections[connectCfgStockSection]),
+		ExQuotes:    connectCfgServers(sections[connectCfgExSection]),
+	}
+	if len(cfg.StockQuotes) == 0 {
+		return nil, fmt.Errorf("%w: 没有[HQHOST]行情线路", ErrInvalidConfig)
+	}
+	return cfg, nil
+}
+
+// LoadConnectCfg 读取通达信客户端的connect.cfg文件
+func LoadConnectCfg(path string) (*ConnectConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	cfg, err := ReadConnectCfg(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// parseConnectCfg 解析INI内容，分组名与键名均转为小写
+func parseConnectCfg(data []byte) (map[string]map[string]string, error) {
+	sections := map[string]map[string]string{}
+	var section map[string]string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+		if line[0] == '[' {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("%w: 第%d行分组名不完整: %q", ErrInvalidConfig, lineNo, line)
+			}
+			name := strings.ToLower(strings.TrimSpace(line[1 : len(line)-1]))
+			if sections[name] == nil {
+				sections[name] = map[string]string{}
+			}
+			section = sections[name]
+			continue
+		}
+		eq := strings.IndexByte(line, '=')
+		// 分组之外的内容与无法识别的行不影响线路读取
+		if eq < 0 || section == nil {
+			continue
+		}
+		section[strings.ToLower(strings.TrimSpace(line[:eq]))] = strings.TrimSpace(line[eq+1:])
+	}
+	return sections, scanner.Err()
+}
+
+// connectCfgServers 按序号读取分组中的线路，如HostName01、IPAddress01、Port01
+func connectCfgServers(section map[string]string) StockQuotesServer {
+	// 序号可能带前导零，保留原样用于拼接其他键名，按数值排序
+	type entry struct {
+		suffix string
+		index  int
+	}
+	var entries []entry
+	for key := range section {
+		if !strings.HasPrefix(key, "ipaddress") {
+			continue
+		}
+		suffix := key[len("ipaddress"):]
+		if index, err := strconv.Atoi(suffix); err == nil {
+			entries = append(entries, entry{suffix: suffix, index: index})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].index < entries[j].index
+	})
+	var srvs StockQuotesServer
+	for _, e := range entries {
+		srv := Server{
+			Name: section["hostname"+e.suffix],
+			IP:   section["ipaddress"+e.suffix],
+		}
+		srv.Port, _ = strconv.Atoi(section["port"+e.suffix])
+		if srv.IP == "" || srv.Port <= 0 || srv.Port > 65535 {
+			_logger.Warnf("忽略connect.cfg中的无效线路%s: %q", e.suffix, srv.Addr())
+			continue
+		}
+		srvs = append(srvs, srv)
+	}
+	return srvs
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"github.com/cyclegen-community/tdx-go/utils/parse"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 节选自通达信客户端的connect.cfg
+const connectCfg = `[HQHOST]
+HostNum=3
+PrimaryHost=0
+HostName01=深圳双线主站1
+IPAddress01=110.41.147.114
+Port01=7709
+HostName02=上海双线主站1
+IPAddress02=124.71.187.122
+Port02=7709
+HostName10=无效线路
+IPAddress10=
+Port10=7709
+
+[DSHOST]
+HostNum=1
+HostName01=扩展市场深圳双线1
+IPAddress01=112.74.214.43
+Port01=7727
+
+[INFOHOST]
+HostName01=资讯主站
+IPAddress01=47.92.127.181
+Port01=7710
+`
+
+func TestReadConnectCfg(t *testing.T) {
+	gbk, err := parse.EncodeGBK([]byte(connectCfg))
+	if !assert.NoError(t, err) {
+		return
+	}
+	// 换行符与编码均按客户端保存的格式
+	gbk = bytes.ReplaceAll(gbk, []byte("\n"), []byte("\r\n"))
+	for _, data := range [][]byte{gbk, []byte(connectCfg)} {
+		cfg, err := ReadConnectCfg(bytes.NewReader(data))
+		if !assert.NoError(t, err) {
+			continue
+		}
+		assert.Equal(t, StockQuotesServer{
+			{Name: "深圳双线主站1", IP: "110.41.147.114", Port: 7709},
+			{Name: "上海双线主站1", IP: "124.71.187.122", Port: 7709},
+		}, cfg.StockQuotes)
+		assert.Equal(t, StockQuotesServer{
+			{Name: "扩展市场深圳双线1", IP: "112.74.214.43", Port: 7727},
+		}, cfg.ExQuotes)
+	}
+
+	_, err = ReadConnectCfg(strings.NewReader("[DSHOST]\nIPAddress01=112.74.214.43\nPort01=7727\n"))
+	assert.True(t, errors.Is(err, ErrInvalidConfig))
+	_, err = ReadConnectCfg(strings.NewReader("[HQHOST\n"))
+	assert.True(t, errors.Is(err, ErrInvalidConfig))
+}
+
+func TestLoadConnectCfg(t *testing.T) {
+	path := filepath.Join(tempDir(t), "connect.cfg")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(connectCfg), 0644))
+	cfg, err := LoadConnectCfg(path)
+	assert.NoError(t, err)
+	assert.Len(t, cfg.StockQuotes, 2)
+
+	_, err = LoadConnectCfg(filepath.Join(tempDir(t), "missing.cfg"))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
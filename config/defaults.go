@@ -0,0 +1,65 @@
+package config
+
+// defaultStockQuotesServer 内置的股票行情线路列表，内容与stock_ip.json相同
+// 作为源码编译进程序，不依赖运行时的工作目录
+var defaultStockQuotesServer = StockQuotesServer{
+	{Name: "北京行情主站1", IP: "106.120.74.86", Port: 7711},
+	{Name: "深圳行情主站", IP: "113.105.73.88", Port: 7709},
+	{Name: "深圳行情主站", IP: "113.105.73.88", Port: 7711},
+	{Name: "上海行情主站", IP: "114.80.80.222", Port: 7711},
+	{Name: "移动行情主站", IP: "117.184.140.156", Port: 7711},
+	{Name: "广州行情主站", IP: "119.147.171.206", Port: 443},
+	{Name: "广州行情主站", IP: "119.147.171.206", Port: 80},
+	{Name: "杭州行情主站", IP: "218.108.50.178", Port: 7711},
+	{Name: "北京行情主站2", IP: "221.194.181.176", Port: 7711},
+	{IP: "106.120.74.86", Port: 7709},
+	{IP: "112.95.140.74", Port: 7709},
+	{IP: "112.95.140.92", Port: 7709},
+	{IP: "112.95.140.93", Port: 7709},
+	{IP: "114.67.61.70", Port: 7709},
+	{IP: "114.80.149.19", Port: 7709},
+	{IP: "114.80.149.22", Port: 7709},
+	{IP: "114.80.149.84", Port: 7709},
+	{IP: "114.80.80.222", Port: 7709},
+	{IP: "115.238.56.198", Port: 7709},
+	{IP: "115.238.90.165", Port: 7709},
+	{IP: "117.184.140.156", Port: 7709},
+	{IP: "119.147.164.60", Port: 7709},
+	{IP: "119.147.171.206", Port: 7709},
+	{IP: "119.29.51.30", Port: 7709},
+	{IP: "121.14.104.70", Port: 7709},
+	{IP: "121.14.104.72", Port: 7709},
+	{IP: "121.14.110.194", Port: 7709},
+	{IP: "121.14.2.7", Port: 7709},
+	{IP: "123.125.108.23", Port: 7709},
+	{IP: "123.125.108.24", Port: 7709},
+	{IP: "124.160.88.183", Port: 7709},
+	{IP: "180.153.18.17", Port: 7709},
+	{IP: "180.153.18.170", Port: 7709},
+	{IP: "180.153.18.171", Port: 7709},
+	{IP: "180.153.39.51", Port: 7709},
+	{IP: "218.108.47.69", Port: 7709},
+	{IP: "218.108.50.178", Port: 7709},
+	{IP: "218.108.98.244", Port: 7709},
+	{IP: "218.75.126.9", Port: 7709},
+	{IP: "218.9.148.108", Port: 7709},
+	{IP: "221.194.181.176", Port: 7709},
+	{IP: "59.173.18.69", Port: 7709},
+	{IP: "60.12.136.250", Port: 7709},
+	{IP: "60.191.117.167", Port: 7709},
+	{IP: "60.28.29.69", Port: 7709},
+	{IP: "61.135.142.73", Port: 7709},
+	{IP: "61.135.142.88", Port: 7709},
+	{IP: "61.152.107.168", Port: 7721},
+	{IP: "61.152.249.56", Port: 7709},
+	{IP: "61.153.144.179", Port: 7709},
+	{IP: "61.153.209.138", Port: 7709},
+	{IP: "61.153.209.139", Port: 7709},
+	{IP: "hq.cjis.cn", Port: 7709},
+	{IP: "hq1.daton.com.cn", Port: 7709},
+	{IP: "jstdx.gtjas.com", Port: 7709},
+	{IP: "shtdx.gtjas.com", Port: 7709},
+	{IP: "sztdx.gtjas.com", Port: 7709},
+	{IP: "113.105.142.162", Port: 7721},
+	{IP: "23.129.245.199", Port: 7721},
+}
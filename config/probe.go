@@ -0,0 +1,204 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sparrc/go-ping"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ProbeMode 线路测速方式
+type ProbeMode int
+
+const (
+	// ProbeTCP 测量建立TCP连接的耗时，无需特权，也是行情连接实际经历的延迟
+	ProbeTCP ProbeMode = iota
+	// ProbeICMP 使用ping测速，需要root权限，且在屏蔽ICMP的网络中无法使用
+	ProbeICMP
+)
+
+// ErrUnreachable 测速失败次数超过一半，测速失败的错误均可用errors.Is判断
+var ErrUnreachable = errors.New("线路无法连通")
+
+// ProbeOptions 测速参数
+type ProbeOptions struct {
+	Mode ProbeMode
+	// Count 每条线路的测速次数，默认3次
+	Count int
+	// Timeout 单次测速的超时时间，默认1秒
+	Timeout time.Duration
+	// Concurrency 同时测速的线路数，默认32
+	Concurrency int
+}
+
+func (opts *ProbeOptions) defaults() {
+	if opts.Count <= 0 {
+		opts.Count = 3
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = time.Second
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 32
+	}
+}
+
+// ServerLatency 单条线路的测速结果，Err非空时线路不可用
+type ServerLatency struct {
+	Server  Server
+	Latency time.Duration
+	// Loss 失败次数占比
+	Loss float64
+	Err  error
+}
+
+// Rank 对列表中的线路测速，按延迟从低到高排序，不可用的线路排在最后
+// 配置了代理的线路始终经代理测量TCP连接耗时
+func (srvs StockQuotesServer) Rank(ctx context.Context, opts ProbeOptions) []ServerLatency {
+	opts.defaults()
+	results := make([]ServerLatency, len(srvs))
+	sem := make(chan struct{}, opts.Concurrency)
+	var wg sync.WaitGroup
+	for idx := range srvs {
+		results[idx].Server = srvs[idx]
+		if srvs[idx].IP == "" {
+			results[idx].Err = ErrUnreachable
+			continue
+		}
+		wg.Add(1)
+		go func(result *ServerLatency) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				result.Err = fmt.Errorf("%w: %v", ErrUnreachable, ctx.Err())
+				return
+			}
+			if opts.Mode == ProbeICMP && result.Server.Proxy == "" {
+				*result = pingLatency(ctx, result.Server, opts)
+			} else {
+				*result = dialLatency(ctx, result.Server, opts)
+			}
+			if result.Err != nil {
+				_logger.Warnf("测速 %s 失败: %v", result.Server.Addr(), result.Err)
+			}
+		}(&results[idx])
+	}
+	wg.Wait()
+	sort.SliceStable(results, func(i, j int) bool {
+		if (results[i].Err == nil) != (results[j].Err == nil) {
+			return results[i].Err == nil
+		}
+		return results[i].Latency < results[j].Latency
+	})
+	return results
+}
+
+// BestServer 以TCP连接耗时测速并返回延迟最低的线路，所有线路均无法连通时返回ErrUnreachable
+func (srvs StockQuotesServer) BestServer() (Server, error) {
+	ranked := srvs.Rank(context.Background(), ProbeOptions{})
+	if len(ranked) == 0 {
+		return Server{}, fmt.Errorf("%w: 线路列表为空", ErrUnreachable)
+	}
+	if ranked[0].Err != nil {
+		return Server{}, fmt.Errorf("所有线路均无法连通: %w", ranked[0].Err)
+	}
+	return ranked[0].Server, nil
+}
+
+// Best 以TCP连接耗时测速并返回延迟最低的线路，所有线路均无法连通时panic
+//
+// Deprecated: 请使用BestServer自行处理错误
+func (srvs StockQuotesServer) Best() Server {
+	srv, err := srvs.BestServer()
+	if err != nil {
+		panic(err)
+	}
+	return srv
+}
+
+// dialLatency 测量建立TCP连接的平均耗时，半数以上失败时视为不可用
+func dialLatency(ctx context.Context, srv Server, opts ProbeOptions) ServerLatency {
+	result := ServerLatency{Server: srv}
+	dialer, err := srv.Dialer()
+	if err != nil {
+		result.Err = fmt.Errorf("%w: %v", ErrUnreachable, err)
+		return result
+	}
+	var total time.Duration
+	var succeeded int
+	var lastErr error
+	for i := 0; i < opts.Count; i++ {
+		dialCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+		start := time.Now()
+		conn, err := dialer.DialContext(dialCtx, "tcp", srv.Addr())
+		elapsed := time.Since(start)
+		cancel()
+		if err != nil {
+			lastErr = err
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		conn.Close()
+		total += elapsed
+		succeeded++
+	}
+	result.Loss = 1 - float64(succeeded)/float64(opts.Count)
+	// 与ping一致，失败率不能高于50%
+	if result.Loss > 0.5 {
+		result.Err = fmt.Errorf("%w: %v", ErrUnreachable, lastErr)
+		return result
+	}
+	result.Latency = total / time.Duration(succeeded)
+	return result
+}
+
+// pingLatency 使用ICMP测速，需要root权限
+// ctx的截止时间会缩短测速的总超时；ctx取消时立即返回ErrUnreachable
+// go-ping的Stop与其内部的超时处理会重复关闭同一通道而崩溃，因此不调用Stop，未完成的测速在总超时后自行结束
+func pingLatency(ctx context.Context, srv Server, opts ProbeOptions) ServerLatency {
+	result := ServerLatency{Server: srv}
+	pinger, err := ping.NewPinger(srv.IP)
+	if err != nil {
+		result.Err = fmt.Errorf("%w: %v", ErrUnreachable, err)
+		return result
+	}
+	pinger.SetPrivileged(true)
+	pinger.Count = opts.Count
+	pinger.Timeout = opts.Timeout * time.Duration(opts.Count)
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < pinger.Timeout {
+		pinger.Timeout = time.Until(deadline)
+	}
+	// 超时不为正数时go-ping无法创建计时器
+	if pinger.Timeout <= 0 {
+		result.Err = fmt.Errorf("%w: %v", ErrUnreachable, context.DeadlineExceeded)
+		return result
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pinger.Run()
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		result.Err = fmt.Errorf("%w: %v", ErrUnreachable, ctx.Err())
+		return result
+	}
+
+	stats := pinger.Statistics() // get send/receive/rtt stats
+	result.Loss = stats.PacketLoss / 100
+	// 丢包率不能高于50%
+	if result.Loss > 0.5 {
+		result.Err = ErrUnreachable
+		return result
+	}
+	result.Latency = stats.AvgRtt
+	return result
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+// listenServer 在本地端口上接受并立即关闭连接
+func listenServer(t *testing.T, name string) Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	addr := ln.Addr().(*net.TCPAddr)
+	return Server{Name: name, IP: addr.IP.String(), Port: addr.Port}
+}
+
+func TestStockQuotesServer_Rank(t *testing.T) {
+	srvs := StockQuotesServer{
+		{Name: "closed", IP: "127.0.0.1", Port: 1},
+		listenServer(t, "a"),
+		{Name: "empty"},
+		listenServer(t, "b"),
+	}
+	ranked := srvs.Rank(context.Background(), ProbeOptions{Timeout: 100 * time.Millisecond})
+	if !assert.Len(t, ranked, 4) {
+		return
+	}
+	for _, result := range ranked[:2] {
+		assert.NoError(t, result.Err)
+		assert.True(t, result.Latency > 0)
+		assert.Equal(t, 0.0, result.Loss)
+	}
+	assert.True(t, ranked[0].Latency <= ranked[1].Latency)
+	for _, result := range ranked[2:] {
+		assert.True(t, errors.Is(result.Err, ErrUnreachable), result.Server.Name)
+	}
+	// 不可用的线路保持原有顺序
+	assert.Equal(t, "closed", ranked[2].Server.Name)
+	assert.Equal(t, 1.0, ranked[2].Loss)
+	assert.Equal(t, "empty", ranked[3].Server.Name)
+}
+
+func TestStockQuotesServer_Best(t *testing.T) {
+	srv := listenServer(t, "a")
+	best, err := StockQuotesServer{{Name: "closed", IP: "127.0.0.1", Port: 1}, srv}.BestServer()
+	assert.NoError(t, err)
+	assert.Equal(t, srv, best)
+
+	_, err = StockQuotesServer{{Name: "closed", IP: "127.0.0.1", Port: 1}}.BestServer()
+	assert.True(t, errors.Is(err, ErrUnreachable))
+	_, err = StockQuotesServer{}.BestServer()
+	assert.True(t, errors.Is(err, ErrUnreachable))
+
+	// 保留的旧接口
+	assert.Equal(t, srv, StockQuotesServer{srv}.Best())
+	assert.Panics(t, func() { StockQuotesServer{}.Best() })
+}
+
+func TestStockQuotesServer_RankCancel(t *testing.T) {
+	srvs := StockQuotesServer{listenServer(t, "a"), listenServer(t, "b"), listenServer(t, "c")}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// 取消后无论是否已开始测速，错误均可用ErrUnreachable判断
+	for _, result := range srvs.Rank(ctx, ProbeOptions{Concurrency: 1}) {
+		assert.True(t, errors.Is(result.Err, ErrUnreachable), result.Server.Name)
+	}
+
+	ctx, cancel = context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	result := pingLatency(ctx, Server{IP: "127.0.0.1"}, ProbeOptions{Count: 3, Timeout: time.Second})
+	assert.True(t, errors.Is(result.Err, ErrUnreachable))
+}
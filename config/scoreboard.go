@@ -0,0 +1,55 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"github.com/cyclegen-community/tdx-go/core"
+)
+
+// Select 根据记分板选择线路，记分板中有近期的良好记录时直接使用，不再逐一测速
+// 否则体检全部线路，将结果记入记分板后再选择，所有线路均不可用时返回ErrUnreachable
+// board为nil时每次都体检全部线路，返回排在最前的健康线路
+func (srvs StockQuotesServer) Select(ctx context.Context, board *core.Scoreboard, opts ...core.Option) (Server, error) {
+	addrs := make([]string, len(srvs))
+	byAddr := make(map[string]Server, len(srvs))
+	for idx, srv := range srvs {
+		addrs[idx] = srv.Addr()
+		if _, ok := byAddr[addrs[idx]]; !ok {
+			byAddr[addrs[idx]] = srv
+		}
+	}
+	if board != nil {
+		if addr, ok := board.Best(addrs); ok {
+			return byAddr[addr], nil
+		}
+	}
+	// 配置了代理的线路经代理体检，opts用于设置超时等其他连接参数
+	reports := core.CheckHealthFunc(ctx, addrs, func(addr string) ([]core.Option, error) {
+		srv := byAddr[addr]
+		dialer, err := srv.Dialer()
+		if err != nil {
+			return nil, err
+		}
+		return append(opts[:len(opts):len(opts)], core.WithProxy(dialer)), nil
+	})
+	for _, report := range reports {
+		if report.Err != nil {
+			_logger.Warnf("体检 %s 失败: %v", report.Addr, report.Err)
+		}
+		if board != nil && ctx.Err() == nil {
+			board.RecordHealth(report)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return Server{}, err
+	}
+	if board == nil {
+		// 体检结果已按健康程度与延迟排序
+		if len(reports) > 0 && reports[0].Healthy() {
+			return byAddr[reports[0].Addr], nil
+		}
+	} else if addr, ok := board.Best(addrs); ok {
+		return byAddr[addr], nil
+	}
+	return Server{}, fmt.Errorf("%w: 没有体检通过的线路", ErrUnreachable)
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"github.com/cyclegen-community/tdx-go/core"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestStockQuotesServer_Select(t *testing.T) {
+	srvs := StockQuotesServer{
+		{Name: "a", IP: "127.0.0.1", Port: 1},
+		{Name: "b", IP: "127.0.0.1", Port: 2},
+	}
+	// 记分板中有良好记录时直接选择，不再体检
+	board := core.NewScoreboard()
+	board.Record(srvs[0].Addr(), 30*time.Millisecond, nil)
+	board.Record(srvs[1].Addr(), 10*time.Millisecond, nil)
+	srv, err := srvs.Select(context.Background(), board)
+	assert.NoError(t, err)
+	assert.Equal(t, "b", srv.Name)
+
+	// 没有记录时体检全部线路并记入记分板
+	board = core.NewScoreboard()
+	_, err = srvs.Select(context.Background(), board, core.WithTimeout(100*time.Millisecond))
+	assert.True(t, errors.Is(err, ErrUnreachable))
+	scores := board.Scores()
+	if assert.Len(t, scores, 2) {
+		assert.Equal(t, 1, scores[0].Failures)
+	}
+
+	// 不使用记分板时直接体检
+	_, err = srvs.Select(context.Background(), nil, core.WithTimeout(100*time.Millisecond))
+	assert.True(t, errors.Is(err, ErrUnreachable))
+}
@@ -0,0 +1,235 @@
+package core
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"github.com/cyclegen-community/tdx-go/proto"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// CacheStore 响应缓存的存储后端，键为序列化后的请求，值为响应包体
+// 缓存只是优化手段，存储失败时静默忽略
+// Set之后value归存储所有，调用方不再修改；Get返回的切片归调用方所有，命中时会直接交给响应解析，存储不得再引用它
+type CacheStore interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte, ttl time.Duration)
+}
+
+// CachePolicy 按指令设置响应的缓存时间
+type CachePolicy struct {
+	// TTLs 按指令名称设置缓存时间，0表示不缓存
+	TTLs map[string]time.Duration
+	// Default 未在TTLs中列出的指令的缓存时间，实时行情类指令不使用该值
+	Default time.Duration
+	// Classify 指令归类方法，为空时使用DefaultClassify
+	Classify func(proto.Marshaler) CommandClass
+}
+
+// DefaultCachePolicy 只缓存变化很少的证券数量与证券列表
+var DefaultCachePolicy = CachePolicy{
+	TTLs: map[string]time.Duration{
+		"GetSecurityCount": time.Hour,
+		"GetSecurityList":  time.Hour,
+	},
+}
+
+// ttl 返回请求的缓存时间，实时行情只有在TTLs中显式列出时才会缓存
+func (p *CachePolicy) ttl(request proto.Marshaler) time.Duration {
+	if ttl, ok := p.TTLs[proto.CommandName(request)]; ok {
+		return ttl
+	}
+	classify := p.Classify
+	if classify == nil {
+		classify = DefaultClassify
+	}
+	if classify(request) == ClassQuotes {
+		return 0
+	}
+	return p.Default
+}
+
+// cacheKey 以序列化后的请求作为缓存键，序号每次请求都不同，不计入键中
+func cacheKey(request proto.Marshaler) (string, error) {
+	data, err := request.Marshal()
+	if err != nil {
+		return "", err
+	}
+	if _, ok := request.(proto.Request); ok && len(data) >= proto.RequestHeaderSize {
+		data = append([]byte(nil), data...)
+		binary.LittleEndian.PutUint32(data[1:], 0)
+	}
+	return string(data), nil
+}
+
+// recordingResponse 在解析响应的同时保留一份响应包体
+type recordingResponse struct {
+	proto.Unmarshaler
+	data []byte
+}
+
+// BufferSafe 包体另行复制保存，能否直接解析缓冲池中的数据取决于被包装的响应
+func (r *recordingResponse) BufferSafe() bool {
+	return proto.IsBufferSafe(r.Unmarshaler)
+}
+
+func (r *recordingResponse) Unmarshal(data []byte) error {
+	if err := r.Unmarshaler.Unmarshal(data); err != nil {
+		return err
+	}
+	r.data = append([]byte(nil), data...)
+	return nil
+}
+
+// CacheInterceptor 缓存成功的响应，命中时不再发送请求
+func CacheInterceptor(store CacheStore, policy CachePolicy) Interceptor {
+	return func(ctx context.Context, request proto.Marshaler, response proto.Unmarshaler, next Invoker) error {
+		ttl := policy.ttl(request)
+		if ttl <= 0 {
+			return next(ctx, request, response)
+		}
+		key, err := cacheKey(request)
+		if err != nil {
+			return next(ctx, request, response)
+		}
+		if data, ok := store.Get(key); ok {
+			// 缓存内容无法解析时视为未命中
+			if response.Unmarshal(data) == nil {
+				markCacheHit(ctx)
+				return nil
+			}
+		}
+		recorder := &recordingResponse{Unmarshaler: response}
+		if err := next(ctx, request, recorder); err != nil {
+			return err
+		}
+		store.Set(key, recorder.data, ttl)
+		return nil
+	}
+}
+
+// MemoryCache 基于LRU淘汰的内存缓存
+type MemoryCache struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewMemoryCache 创建最多保存capacity条响应的内存缓存
+func NewMemoryCache(capacity int) *MemoryCache {
+	return &MemoryCache{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    map[string]*list.Element{},
+	}
+}
+
+func (c *MemoryCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		c.ll.Remove(elem)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.ll.MoveToFront(elem)
+	// 响应可能保留解析时传入的数据，返回副本避免其与缓存中的内容相互影响
+	return append([]byte(nil), entry.value...), true
+}
+
+func (c *MemoryCache) Set(key string, value []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := time.Now().Add(ttl)
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*cacheEntry)
+		entry.value, entry.expires = value, expires
+		c.ll.MoveToFront(elem)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&cacheEntry{key: key, value: value, expires: expires})
+	for c.capacity > 0 && c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// Len 返回缓存中的条目数，包含已过期但尚未清理的条目
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// DiskCache 将响应保存在目录中的磁盘缓存，进程重启后仍然有效
+// 每条响应一个文件，文件名为缓存键的sha256，内容为8字节过期时间与响应包体
+type DiskCache struct {
+	dir string
+}
+
+// NewDiskCache 在dir中创建磁盘缓存，目录不存在时自动创建
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &DiskCache{dir: dir}, nil
+}
+
+func (c *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
+
+func (c *DiskCache) Get(key string) ([]byte, bool) {
+	path := c.path(key)
+	data, err := ioutil.ReadFile(path)
+	if err != nil || len(data) < 8 {
+		return nil, false
+	}
+	expires := time.Unix(0, int64(binary.LittleEndian.Uint64(data)))
+	if time.Now().After(expires) {
+		os.Remove(path)
+		return nil, false
+	}
+	return data[8:], true
+}
+
+func (c *DiskCache) Set(key string, value []byte, ttl time.Duration) {
+	data := make([]byte, 8, 8+len(value))
+	binary.LittleEndian.PutUint64(data, uint64(time.Now().Add(ttl).UnixNano()))
+	data = append(data, value...)
+	// 先写入临时文件再改名，避免并发读取到不完整的内容
+	tmp, err := ioutil.TempFile(c.dir, "tmp-")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path(key))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+}
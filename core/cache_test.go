@@ -0,0 +1,121 @@
+package core
+
+import (
+	"context"
+	"github.com/cyclegen-community/tdx-go/proto/v1"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoryCache(t *testing.T) {
+	cache := NewMemoryCache(2)
+	cache.Set("a", []byte("1"), time.Hour)
+	cache.Set("b", []byte("2"), time.Hour)
+	// 访问a后b成为最久未使用的条目
+	_, ok := cache.Get("a")
+	assert.True(t, ok)
+	cache.Set("c", []byte("3"), time.Hour)
+	_, ok = cache.Get("b")
+	assert.False(t, ok)
+	value, ok := cache.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), value)
+	assert.Equal(t, 2, cache.Len())
+	// 修改返回的数据不影响缓存
+	value[0] = '9'
+	value, _ = cache.Get("a")
+	assert.Equal(t, []byte("1"), value)
+
+	cache.Set("d", []byte("4"), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	_, ok = cache.Get("d")
+	assert.False(t, ok)
+}
+
+func TestDiskCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tdx-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cache, err := NewDiskCache(dir)
+	assert.NoError(t, err)
+
+	cache.Set("key", []byte("value"), time.Hour)
+	// 重新打开后仍然有效
+	cache, _ = NewDiskCache(dir)
+	value, ok := cache.Get("key")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("value"), value)
+
+	cache.Set("key", []byte("value"), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	_, ok = cache.Get("key")
+	assert.False(t, ok)
+	files, _ := ioutil.ReadDir(dir)
+	assert.Empty(t, files)
+}
+
+func TestCacheInterceptor(t *testing.T) {
+	var requests int32
+	srv := newFakeServer(t, func(cmd uint16, body []byte) ([]byte, bool) {
+		if cmd == 0x044e || cmd == uint16(v1.CmdGetSecurityQuotes) {
+			atomic.AddInt32(&requests, 1)
+		}
+		if cmd == uint16(v1.CmdGetSecurityQuotes) {
+			return []byte{0, 0, 0, 0}, true
+		}
+		return body, true
+	})
+	host, port := srv.addr()
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	policy := CachePolicy{TTLs: DefaultCachePolicy.TTLs, Default: time.Hour}
+	cli, err := Dial(context.Background(), addr, WithCache(NewMemoryCache(16), policy))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer cli.Close()
+
+	count := func(market v1.Market) uint {
+		req, resp, _ := v1.NewGetSecurityCount(market)
+		assert.NoError(t, cli.Do(req, resp))
+		return resp.Count
+	}
+	assert.Equal(t, uint(v1.MarketShangHai), count(v1.MarketShangHai))
+	assert.Equal(t, uint(v1.MarketShangHai), count(v1.MarketShangHai))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	// 参数不同的请求各自缓存
+	assert.Equal(t, uint(v1.MarketShenZhen), count(v1.MarketShenZhen))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+
+	// 命中时保留数据的响应收到的是副本，修改它不影响后续命中
+	countReq, _, _ := v1.NewGetSecurityCount(v1.MarketShangHai)
+	retained := &retainingResponse{}
+	assert.NoError(t, cli.Do(countReq, retained))
+	retained.data[0] = 0xff
+	assert.Equal(t, uint(v1.MarketShangHai), count(v1.MarketShangHai))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+
+	// 实时行情不使用默认缓存时间
+	quotes := func() {
+		req, resp, _ := v1.NewGetSecurityQuotes([]v1.Security{{Market: v1.MarketShangHai, Code: "600000"}})
+		assert.NoError(t, cli.Do(req, resp))
+	}
+	quotes()
+	quotes()
+	assert.Equal(t, int32(4), atomic.LoadInt32(&requests))
+
+	// 显式设置缓存时间后才缓存
+	policy.TTLs = map[string]time.Duration{"GetSecurityQuotes": time.Second}
+	cli, _ = Dial(context.Background(), addr, WithCache(NewMemoryCache(16), policy))
+	defer cli.Close()
+	quotes()
+	quotes()
+	assert.Equal(t, int32(5), atomic.LoadInt32(&requests))
+}
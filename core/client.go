@@ -1,128 +1,470 @@
 package core
 
 import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"github.com/cyclegen-community/tdx-go/proto"
-	"github.com/cyclegen-community/tdx-go/utils"
-	"log"
+	"github.com/cyclegen-community/tdx-go/utils/logger"
 	"net"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// Client 行情服务器客户端，可在多个协程中并发使用
+// 并发的请求在连接上排队依次收发，不会相互交错
 type Client struct {
+	mu            sync.Mutex
 	conn          net.Conn
+	fr            *frameReader
+	state         ConnState
+	lastActive    time.Time
+	heartbeatStop chan struct{}
+	dialContext   DialContextFunc
+	logger        logger.Logger
+	debug         bool
+	interceptors  []Interceptor
+	metrics       *Metrics
+	invoker       Invoker
+	scoreboard    *Scoreboard
+	// 经连接池发出、尚未完成的请求，连接池迁移时据此等待请求完成
+	inflight sync.WaitGroup
+	// 创建时使用的配置项，连接池迁移连接时据此创建新的客户端
+	opts []Option
+	// 应用配置项时发现的问题，在全部配置项应用后输出，此时日志输出已经确定
+	optionWarnings []error
+	// 连接状态变化时的回调，见WithStateChange
+	onStateChange func(old, new ConnState)
+	// 连接建立后启动心跳的间隔，0表示不启动
+	heartbeatInterval time.Duration
+	// 同一连接上的请求与心跳必须串行收发，容量为1的信号量，排队时可响应ctx取消
+	sem chan struct{}
+	// 最近一次分配的请求序号
+	seq           uint32
 	Host          string
 	Port          int
 	Timeout       time.Duration
 	MaxRetryTimes int
 	RetryDuration time.Duration
+	// MaxBodySize 响应包体压缩前后长度的上限，超出时跳过该响应并返回ErrFrameTooLarge
+	// 默认为协议允许的最大值0xffff，即不做限制
+	MaxBodySize int
+	// Handshake 为true时每次建立连接后自动完成SetupCmd1/2/3握手
+	Handshake bool
 }
 
-// https://www.sohamkamani.com/golang/options-pattern/
-// https://lingchao.xin/post/functional-options-pattern-in-go.html
-// NewBaseClient 创建BaseClient实例
-func NewClient(host string, port int) *Client {
-	addr := strings.Join([]string{host, strconv.Itoa(port)}, ":")
-
-	conn, err := net.Dial("tcp", addr) // net.DialTimeout()
-
-	if err != nil {
-		log.Fatalln(err)
-	}
-	return &Client{
-		conn:          conn,
+// newClient 创建带默认配置的未连接实例
+func newClient(host string, port int, opts ...Option) *Client {
+	cli := &Client{
 		Host:          host,
 		Port:          port,
 		MaxRetryTimes: 5,
-		Timeout:       time.Second,
+		Timeout:       defaultTimeout,
 		RetryDuration: time.Millisecond * 200,
+		MaxBodySize:   defaultMaxBodySize,
+		Handshake:     true,
+		sem:           make(chan struct{}, 1),
+		metrics:       DefaultMetrics,
+		logger:        logger.Nop,
+		opts:          append([]Option(nil), opts...),
+	}
+	for _, opt := range opts {
+		opt(cli)
 	}
+	for _, err := range cli.optionWarnings {
+		cli.logger.Warnf("%v", err)
+	}
+	cli.invoker = chainInterceptors(cli.interceptors, cli.invoke)
+	return cli
 }
 
-func (cli *Client) Do(request proto.Marshaler, response proto.Unmarshaler) error {
-	// 序列化请求
-	req, err := request.Marshal()
+// NewClient 创建Client实例并尝试连接
+// 连接失败时仅记录日志，返回的实例会在下一次请求时重连
+//
+// Deprecated: 使用Dial以获得连接错误
+func NewClient(host string, port int) *Client {
+	cli := newClient(host, port)
+	err := cli.connect(context.Background())
 	if err != nil {
-		return err
-	}
-	// 发送请求
-	retryTimes := 0
-SEND:
-	n, err := cli.conn.Write(req)
-	// 重试
-	if n < len(req) {
-		retryTimes += 1
-		if retryTimes <= cli.MaxRetryTimes {
-			log.Printf("第%d次重试\n", retryTimes)
-			goto SEND
-		} else {
-			return errors.New("数据未完整发送")
-		}
+		cli.logger.Warnf("连接%s失败: %v", cli.Addr(), err)
 	}
+	return cli
+}
+
+// Dial 连接到addr(host:port)并返回Client实例
+// 连接失败时按配置重试，重试耗尽或ctx被取消时返回错误
+func Dial(ctx context.Context, addr string, opts ...Option) (*Client, error) {
+	host, portStr, err := net.SplitHostPort(addr)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	// 解析响应包头
-	var header proto.PacketHeader
-	// 读取包头 大小为16字节
-	// 单次获取的字列流
-	headerLength := 0x10
-	headerBytes := make([]byte, headerLength)
-	// 调用socket获取字节流并保存到data中
-	headerBytes, err = cli.receive(headerLength)
+	port, err := strconv.Atoi(portStr)
 	if err != nil {
-		//log.Println(err)
-		return err
+		return nil, err
 	}
-	err = header.Unmarshal(headerBytes)
-	if err != nil {
-		return err
+	cli := newClient(host, port, opts...)
+	// 整个重试过程作为一次连接计入记分板，重试不会被重复记为失败
+	if cli.scoreboard != nil {
+		err = cli.scoreboard.guard(ctx, cli.Addr(), func() error {
+			return cli.connectRetry(ctx)
+		})
+	} else {
+		err = cli.connectRetry(ctx)
 	}
-	// 根据获取响应体结构
-	// 调用socket获取字节流并保存到data中
-	bodyBytes, err := cli.receive(header.ZipSize)
 	if err != nil {
+		return nil, err
+	}
+	cli.StartHeartbeat(cli.heartbeatInterval)
+	return cli, nil
+}
+
+// connectRetry 建立连接，失败时按配置重试
+func (cli *Client) connectRetry(ctx context.Context) error {
+	for retryTimes := 0; ; retryTimes++ {
+		err := cli.connect(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if retryTimes >= cli.MaxRetryTimes {
+			return err
+		}
+		cli.logger.Warnf("第%d次重试连接%s: %v", retryTimes+1, cli.Addr(), err)
+		if err := sleepContext(ctx, cli.backoff(retryTimes)); err != nil {
+			return err
+		}
+	}
+}
+
+const (
+	// 默认的连接与单次请求超时时间
+	defaultTimeout = time.Second
+	// 重连退避的最长等待时间
+	maxRetryBackoff = 5 * time.Second
+)
+
+// Addr 服务器地址
+func (cli *Client) Addr() string {
+	return strings.Join([]string{cli.Host, strconv.Itoa(cli.Port)}, ":")
+}
+
+// Do 发送请求并等待响应, 单次请求耗时受Timeout约束
+func (cli *Client) Do(request proto.Marshaler, response proto.Unmarshaler) error {
+	return cli.DoContext(context.Background(), request, response)
+}
+
+// DoContext 发送请求并等待响应
+// ctx的截止时间与Timeout中较早者作为本次读写的截止时间，ctx被取消时立即中断读写并返回ctx.Err()
+// 连接断开时按退避策略重连并重新握手，可重放的请求(见proto.Idempotent)会在新连接上重试
+// 通过WithInterceptors配置的拦截器依次包裹在外层
+// 每次调用在统计中计为一次请求，包含拦截器的耗时与结果，见CommandStats
+func (cli *Client) DoContext(ctx context.Context, request proto.Marshaler, response proto.Unmarshaler) error {
+	trace := &requestTrace{}
+	start := time.Now()
+	err := cli.invoker(withTrace(ctx, trace), request, response)
+	cli.metrics.recordRequest(cli.Addr(), proto.CommandName(request), time.Since(start), trace.cacheHit, err)
+	return err
+}
+
+// invoke 拦截器链末端的实际请求逻辑
+func (cli *Client) invoke(ctx context.Context, request proto.Marshaler, response proto.Unmarshaler) error {
+	if err := ctx.Err(); err != nil {
 		return err
 	}
-	// zlib解压缩
-	if header.Compressed() {
-		bodyBytes, err = utils.ZlibUnCompress(bodyBytes)
+	for retryTimes := 0; ; retryTimes++ {
+		sent, broken, err := cli.attempt(ctx, request, response)
+		if !broken {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// 请求已发出后连接中断，只有可重放的请求才能重试
+		if retryTimes >= cli.MaxRetryTimes || (sent && !proto.IsIdempotent(request)) {
+			return err
+		}
+		cli.logger.Warnf("第%d次重试: %v", retryTimes+1, err)
+		if err := sleepContext(ctx, cli.backoff(retryTimes)); err != nil {
+			return err
+		}
+	}
+}
+
+// attempt 占用连接完成一次请求，必要时先重新建立连接
+// sent表示请求是否已经发出，broken表示失败源于连接，可重连后重试
+func (cli *Client) attempt(ctx context.Context, request proto.Marshaler, response proto.Unmarshaler) (sent, broken bool, err error) {
+	if err := cli.acquire(ctx); err != nil {
+		return false, false, err
+	}
+	defer cli.release()
+	if err := cli.ensureConnected(ctx); err != nil {
+		cli.metrics.recordAttempt(cli.Addr(), &sample{command: proto.CommandName(request)}, err)
+		return false, err != ErrClientClosed, err
+	}
+	broken, err = cli.roundTrip(ctx, request, response)
+	return true, broken, err
+}
+
+// acquire 排队等待独占连接，ctx被取消时放弃等待
+func (cli *Client) acquire(ctx context.Context) error {
+	select {
+	case cli.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// tryAcquire 连接空闲时立即占用，否则返回false
+func (cli *Client) tryAcquire() bool {
+	select {
+	case cli.sem <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (cli *Client) release() {
+	<-cli.sem
+}
+
+// backoff 第retryTimes次重试前的等待时间，按指数增长
+func (cli *Client) backoff(retryTimes int) time.Duration {
+	d := cli.RetryDuration
+	for i := 0; i < retryTimes && d < maxRetryBackoff; i++ {
+		d *= 2
+	}
+	if d > maxRetryBackoff {
+		d = maxRetryBackoff
 	}
-	// 反序列化为响应体结构
-	err = response.Unmarshal(bodyBytes)
+	return d
+}
+
+// sleepContext 等待d时长，ctx被取消时提前返回ctx.Err()
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// ensureConnected 连接不可用时重新建立连接
+func (cli *Client) ensureConnected(ctx context.Context) error {
+	cli.mu.Lock()
+	state, conn := cli.state, cli.conn
+	cli.mu.Unlock()
+	if state == StateClosed {
+		return ErrClientClosed
+	}
+	if conn != nil && state == StateConnected {
+		return nil
+	}
+	return cli.connect(ctx)
+}
+
+// connect 建立TCP连接，并按需完成握手
+func (cli *Client) connect(ctx context.Context) error {
+	cli.setState(StateConnecting)
+	conn, err := cli.dial(ctx)
 	if err != nil {
-		return err
+		cli.setState(StateDisconnected)
+		return &OpError{Op: "dial", Addr: cli.Addr(), Err: err}
+	}
+	cli.mu.Lock()
+	// 连接期间客户端被关闭
+	if cli.state == StateClosed {
+		cli.mu.Unlock()
+		conn.Close()
+		return ErrClientClosed
 	}
+	cli.conn = conn
+	cli.fr = &frameReader{
+		r:           conn,
+		addr:        cli.Addr(),
+		maxBodySize: cli.MaxBodySize,
+		dump:        cli.dumper(),
+	}
+	cli.mu.Unlock()
+	if cli.Handshake {
+		if err = cli.handshake(ctx); err != nil {
+			cli.disconnect(conn)
+			return err
+		}
+	}
+	cli.setState(StateConnected)
 	return nil
 }
-func (cli *Client) receive(length int) (data []byte, err error) {
-	var (
-		receivedSize int
-	)
-READ:
-	tmp := make([]byte, length)
-	// 调用socket获取字节流并保存到data中
-	receivedSize, err = cli.conn.Read(tmp)
-	// socket错误,可能为EOF
+
+// dial 在Timeout约束下建立TCP连接
+func (cli *Client) dial(ctx context.Context) (net.Conn, error) {
+	if cli.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cli.Timeout)
+		defer cancel()
+	}
+	dial := cli.dialContext
+	if dial == nil {
+		var dialer net.Dialer
+		dial = dialer.DialContext
+	}
+	return dial(ctx, "tcp", cli.Addr())
+}
+
+// disconnect 丢弃已损坏的连接
+func (cli *Client) disconnect(conn net.Conn) {
+	cli.mu.Lock()
+	if cli.conn == conn {
+		cli.conn = nil
+	}
+	cli.mu.Unlock()
+	conn.Close()
+	cli.setState(StateDisconnected)
+}
+
+// roundTrip 在当前连接上完成一次请求与响应
+// broken为true表示读写过程中出错，连接已被丢弃
+func (cli *Client) roundTrip(ctx context.Context, request proto.Marshaler, response proto.Unmarshaler) (broken bool, err error) {
+	cli.mu.Lock()
+	conn, fr := cli.conn, cli.fr
+	cli.mu.Unlock()
+	if conn == nil {
+		return true, &OpError{Op: "write", Addr: cli.Addr(), Err: ErrConnClosed}
+	}
+	smp := sample{command: proto.CommandName(request)}
+	defer func() {
+		cli.metrics.recordAttempt(fr.addr, &smp, err)
+	}()
+	// 序列化请求
+	req, err := request.Marshal()
 	if err != nil {
-		return nil, err
+		return false, err
+	}
+	// 分配请求序号，用于校验响应
+	// 序号写入包头副本与序列化后的数据，不修改调用方的请求，同一请求可在多个协程中并发发送
+	var reqHeader *proto.RequestHeader
+	if r, ok := request.(proto.Request); ok && len(req) >= proto.RequestHeaderSize {
+		header := *r.RequestHeader()
+		header.SeqID = atomic.AddUint32(&cli.seq, 1)
+		binary.LittleEndian.PutUint32(req[1:], header.SeqID)
+		reqHeader = &header
 	}
-	// 数据添加到总输出,由于tmp申请内存时使用了length的长度，
-	// 所以直接全部复制到data中会使得未完全传输的部分被填充为0导致数据获取不完整，
-	// 故使用tmp[:receivedSize]
-	data = append(data, tmp[:receivedSize]...)
-	// 数据读满就可以返回了
-	if len(data) == length {
-		return
+	smp.sent = len(req)
+	if err := conn.SetDeadline(cli.deadline(ctx)); err != nil {
+		cli.disconnect(conn)
+		return true, &OpError{Op: "write", Addr: fr.addr, Err: err}
+	}
+	stop := watchContext(ctx, conn)
+	body, err := cli.exchange(conn, fr, req, reqHeader)
+	stop()
+	smp.zipSize, smp.unzipSize = fr.header.ZipSize, fr.header.UnzipSize
+	if errors.Is(err, ErrFrameTooLarge) {
+		// 超长的帧已被整帧跳过，连接仍然可用
+		conn.SetDeadline(time.Time{})
+		return false, err
+	}
+	if err != nil {
+		// 读写出错后连接上的数据流已不可信，丢弃该连接
+		cli.disconnect(conn)
+		if ctx.Err() != nil {
+			return true, ctx.Err()
+		}
+		return true, err
 	}
-	// 读取小于标准尺寸，说明到文件尾或者读取出现了问题没读满，可以返回了
-	if receivedSize < length {
-		goto READ
+	// 响应体缓冲区在解析完成后归还
+	defer putBuffer(body)
+	// 清除截止时间，避免影响下一次请求
+	conn.SetDeadline(time.Time{})
+	cli.mu.Lock()
+	cli.lastActive = time.Now()
+	cli.mu.Unlock()
+	if len(body.b) == 0 {
+		return false, ErrServerRejected
 	}
-	return
+	// 反序列化为响应体结构，未声明BufferSafe的响应可能保留数据，传入副本
+	data := body.b
+	if !proto.IsBufferSafe(response) {
+		data = append([]byte(nil), data...)
+	}
+	err = response.Unmarshal(data)
+	// 解析错误以请求的指令名称标识
+	var decodeErr *proto.DecodeError
+	if errors.As(err, &decodeErr) && decodeErr.Command == "" {
+		decodeErr.Command = smp.command
+	}
+	return false, err
 }
+
+// deadline 计算本次请求的截止时间
+func (cli *Client) deadline(ctx context.Context) time.Time {
+	var deadline time.Time
+	if cli.Timeout > 0 {
+		deadline = time.Now().Add(cli.Timeout)
+	}
+	if d, ok := ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
+	}
+	return deadline
+}
+
+// watchContext 在ctx被取消时将连接的截止时间设为过去，使阻塞中的读写立即返回
+// 返回的stop函数会等待监听协程退出，调用后可安全地重新设置截止时间
+func watchContext(ctx context.Context, conn net.Conn) (stop func()) {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	quit := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Unix(1, 0))
+		case <-quit:
+		}
+	}()
+	return func() {
+		close(quit)
+		<-exited
+	}
+}
+
+// exchange 发送请求数据并读取完整的响应帧
+func (cli *Client) exchange(conn net.Conn, fr *frameReader, req []byte, reqHeader *proto.RequestHeader) (*buffer, error) {
+	if cli.debug {
+		cli.logger.Debugf("发送至%s:\n%s", fr.addr, hex.Dump(req))
+	}
+	// 发送请求
+	n, err := conn.Write(req)
+	if err != nil {
+		return nil, &OpError{Op: "write", Addr: fr.addr, Err: err}
+	}
+	if n < len(req) {
+		return nil, &OpError{Op: "write", Addr: fr.addr, Err: ErrShortWrite}
+	}
+	return fr.readFrame(reqHeader)
+}
+
+// Close 关闭客户端，关闭后不再自动重连
 func (cli *Client) Close() error {
-	return cli.conn.Close()
+	cli.StopHeartbeat()
+	cli.setState(StateClosed)
+	cli.mu.Lock()
+	conn := cli.conn
+	cli.conn = nil
+	cli.mu.Unlock()
+	if conn == nil {
+		return nil
+	}
+	return conn.Close()
 }
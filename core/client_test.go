@@ -0,0 +1,351 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/cyclegen-community/tdx-go/proto"
+	"github.com/cyclegen-community/tdx-go/proto/v1"
+	"github.com/cyclegen-community/tdx-go/proxy"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_DoContextCancel(t *testing.T) {
+	cli := NewClient(newFakeServer(t, stallAfterHandshake).addr())
+	defer cli.Close()
+	cli.Timeout = 0
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	req, resp, _ := v1.NewGetSecurityCount(v1.MarketShangHai)
+	err := cli.DoContext(ctx, req, resp)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestClient_DoContextCancelWhileReconnecting(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cli := newClient("127.0.0.1", 1, WithDialContext(func(context.Context, string, string) (net.Conn, error) {
+		// 重连过程中调用方取消请求
+		cancel()
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	}))
+	defer cli.Close()
+
+	req, resp, _ := v1.NewGetSecurityCount(v1.MarketShangHai)
+	err := cli.DoContext(ctx, req, resp)
+	assert.True(t, errors.Is(err, context.Canceled), "%v", err)
+}
+
+func TestClient_DoContextDeadline(t *testing.T) {
+	cli := NewClient(newFakeServer(t, stallAfterHandshake).addr())
+	defer cli.Close()
+	cli.Timeout = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, resp, _ := v1.NewGetSecurityCount(v1.MarketShangHai)
+	err := cli.DoContext(ctx, req, resp)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestClient_DoTimeout(t *testing.T) {
+	cli := NewClient(newFakeServer(t, stallAfterHandshake).addr())
+	defer cli.Close()
+	cli.Timeout = 50 * time.Millisecond
+	cli.MaxRetryTimes = 0
+
+	req, resp, _ := v1.NewGetSecurityCount(v1.MarketShangHai)
+	err := cli.Do(req, resp)
+	assert.True(t, errors.Is(err, ErrTimeout))
+	assert.True(t, IsTransient(err))
+}
+
+func TestClient_Reconnect(t *testing.T) {
+	srv := newFakeServer(t, echoHandler)
+	var mu sync.Mutex
+	var states []ConnState
+	cli, err := Dial(context.Background(), fakeServerAddr(srv), WithRetryDelay(time.Millisecond),
+		WithStateChange(func(old, new ConnState) {
+			mu.Lock()
+			defer mu.Unlock()
+			states = append(states, new)
+		}))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer cli.Close()
+	// Dial过程中的状态变化同样可以观察到
+	assert.Equal(t, []ConnState{StateConnecting, StateConnected}, states)
+	srv.dropConns()
+
+	req, resp, _ := v1.NewGetSecurityCount(v1.MarketShangHai)
+	err = cli.Do(req, resp)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(v1.MarketShangHai), resp.Count)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []ConnState{StateConnecting, StateConnected, StateDisconnected, StateConnecting, StateConnected}, states)
+}
+
+func TestClient_Heartbeat(t *testing.T) {
+	var beats int32
+	srv := newFakeServer(t, func(cmd uint16, body []byte) ([]byte, bool) {
+		if cmd == 0x044e {
+			atomic.AddInt32(&beats, 1)
+		}
+		return body, true
+	})
+	cli := NewClient(srv.addr())
+	defer cli.Close()
+
+	cli.StartHeartbeat(20 * time.Millisecond)
+	time.Sleep(110 * time.Millisecond)
+	cli.StopHeartbeat()
+	assert.True(t, atomic.LoadInt32(&beats) >= 3)
+	assert.Equal(t, StateConnected, cli.State())
+}
+
+func TestDial(t *testing.T) {
+	host, port := newFakeServer(t, echoHandler).addr()
+	cli, err := Dial(context.Background(), net.JoinHostPort(host, strconv.Itoa(port)),
+		WithTimeout(100*time.Millisecond), WithHandshake(false))
+	if assert.NoError(t, err) {
+		assert.Equal(t, StateConnected, cli.State())
+		cli.Close()
+	}
+	// WithDialer(nil)使用默认的net.Dialer
+	cli, err = Dial(context.Background(), net.JoinHostPort(host, strconv.Itoa(port)), WithDialer(nil))
+	if assert.NoError(t, err) {
+		cli.Close()
+	}
+
+	// 连接被拒绝时重试耗尽后返回错误而不是退出进程
+	var dials int
+	_, err = Dial(context.Background(), "127.0.0.1:1",
+		WithRetries(2), WithRetryDelay(time.Millisecond),
+		WithDialContext(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dials++
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, addr)
+		}))
+	assert.True(t, errors.Is(err, ErrDial))
+	assert.Equal(t, 3, dials)
+}
+
+func TestDial_Proxy(t *testing.T) {
+	host, port := newFakeServer(t, echoHandler).addr()
+	// HTTP CONNECT代理替身
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	var tunnels int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				req, err := http.ReadRequest(bufio.NewReader(conn))
+				if err != nil {
+					return
+				}
+				target, err := net.Dial("tcp", req.Host)
+				if err != nil {
+					return
+				}
+				defer target.Close()
+				atomic.AddInt32(&tunnels, 1)
+				fmt.Fprint(conn, "HTTP/1.1 200 OK\r\n\r\n")
+				go io.Copy(target, conn)
+				io.Copy(conn, target)
+			}()
+		}
+	}()
+
+	dialer, _ := proxy.Parse("http://" + ln.Addr().String())
+	cli, err := Dial(context.Background(), net.JoinHostPort(host, strconv.Itoa(port)), WithProxy(dialer))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer cli.Close()
+	req, resp, _ := v1.NewGetSecurityCount(v1.MarketShangHai)
+	assert.NoError(t, cli.Do(req, resp))
+	assert.Equal(t, uint(v1.MarketShangHai), resp.Count)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&tunnels))
+
+	// nil表示直连
+	cli, err = Dial(context.Background(), net.JoinHostPort(host, strconv.Itoa(port)), WithProxy(nil))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer cli.Close()
+	assert.NoError(t, cli.Do(req, resp))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&tunnels))
+}
+
+// 多个协程并发请求时，每个请求都应收到属于自己的响应
+func TestClient_ConcurrentDo(t *testing.T) {
+	srv := newFakeServer(t, echoHandler)
+	cli := NewClient(srv.addr())
+	defer cli.Close()
+	cli.RetryDuration = time.Millisecond
+	cli.StartHeartbeat(time.Millisecond)
+
+	const workers, requests = 64, 50
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*requests)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(market v1.Market) {
+			defer wg.Done()
+			for i := 0; i < requests; i++ {
+				req, resp, _ := v1.NewGetSecurityCount(market)
+				if err := cli.Do(req, resp); err != nil {
+					errs <- err
+					continue
+				}
+				if resp.Count != uint(market) {
+					errs <- fmt.Errorf("期望%d，实际收到%d", market, resp.Count)
+				}
+			}
+		}(v1.Market(w))
+	}
+	// 压测过程中断开连接，客户端应重连并重放请求
+	time.AfterFunc(10*time.Millisecond, srv.dropConns)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestClient_SharedRequest(t *testing.T) {
+	srv := newFakeServer(t, echoHandler)
+	clients := []*Client{NewClient(srv.addr()), NewClient(srv.addr())}
+	// 同一请求经多个客户端并发发送，序号不写入请求本身
+	req, _, _ := v1.NewGetSecurityCount(v1.MarketShenZhen)
+	var wg sync.WaitGroup
+	for _, cli := range clients {
+		defer cli.Close()
+		for w := 0; w < 4; w++ {
+			wg.Add(1)
+			go func(cli *Client) {
+				defer wg.Done()
+				for i := 0; i < 20; i++ {
+					var resp v1.GetSecurityCountResponse
+					if assert.NoError(t, cli.Do(req, &resp)) {
+						assert.Equal(t, uint(v1.MarketShenZhen), resp.Count)
+					}
+				}
+			}(cli)
+		}
+	}
+	wg.Wait()
+	assert.Equal(t, uint32(0), req.Header.SeqID)
+	assert.Equal(t, uint16(0), req.Header.ZipSize)
+}
+
+// retainingResponse 保留传入的数据，不声明BufferSafe
+type retainingResponse struct {
+	data []byte
+}
+
+func (r *retainingResponse) Unmarshal(data []byte) error {
+	r.data = data
+	return nil
+}
+
+func TestClient_RetainingResponse(t *testing.T) {
+	srv := newFakeServer(t, echoHandler)
+	cli := NewClient(srv.addr())
+	defer cli.Close()
+
+	responses := make([]*retainingResponse, 10)
+	for idx := range responses {
+		req, _, _ := v1.NewGetSecurityCount(v1.Market(idx))
+		responses[idx] = &retainingResponse{}
+		assert.NoError(t, cli.Do(req, responses[idx]))
+	}
+	// 缓冲池中的数据被后续请求复用，保留的应是副本
+	for idx, resp := range responses {
+		assert.Equal(t, uint16(idx), binary.LittleEndian.Uint16(resp.data))
+	}
+	assert.False(t, proto.IsBufferSafe(&retainingResponse{}))
+	assert.True(t, proto.IsBufferSafe(&v1.HeartbeatResponse{}))
+}
+
+func TestClient_ErrorTaxonomy(t *testing.T) {
+	srv := newFakeServer(t, func(cmd uint16, body []byte) ([]byte, bool) {
+		switch cmd {
+		case 0x044e:
+			// 空响应体
+			return nil, true
+		case 0x0450:
+			// 响应体长度不足以解析
+			return []byte{0x01}, true
+		}
+		return body, true
+	})
+	cli := NewClient(srv.addr())
+	defer cli.Close()
+
+	countReq, countResp, _ := v1.NewGetSecurityCount(v1.MarketShangHai)
+	err := cli.Do(countReq, countResp)
+	assert.True(t, errors.Is(err, ErrServerRejected))
+	assert.False(t, IsTransient(err))
+
+	listReq, listResp, _ := v1.NewGetSecurityList(v1.MarketShangHai, 0)
+	err = cli.Do(listReq, listResp)
+	var decodeErr *proto.DecodeError
+	assert.True(t, errors.Is(err, proto.ErrDecode))
+	if assert.True(t, errors.As(err, &decodeErr)) {
+		// 以指令名称而非内部类型名标识
+		assert.Equal(t, "GetSecurityList", decodeErr.Command)
+		assert.NotContains(t, err.Error(), "*v1.")
+	}
+	assert.False(t, IsTransient(err))
+	// 确定性错误不会断开连接
+	assert.Equal(t, StateConnected, cli.State())
+}
+
+func TestClient_ResponseMismatch(t *testing.T) {
+	var requests int32
+	srv := startFakeServer(t, &fakeServer{
+		handler: echoHandler,
+		corrupt: func(resp []byte) {
+			// 握手之后的第一个响应使用错误的序号
+			if atomic.AddInt32(&requests, 1) == 4 {
+				resp[5]++
+			}
+		},
+	})
+	cli := NewClient(srv.addr())
+	defer cli.Close()
+	cli.MaxRetryTimes = 0
+
+	req, resp, _ := v1.NewGetSecurityCount(v1.MarketShangHai)
+	err := cli.Do(req, resp)
+	var mismatch *proto.MismatchError
+	if assert.True(t, errors.As(err, &mismatch)) {
+		// 握手占用了前三个序号
+		assert.Equal(t, uint32(4), mismatch.SeqID)
+		assert.Equal(t, mismatch.SeqID+1, mismatch.ResponseSeqID)
+		assert.Equal(t, v1.CmdGetSecurityCount, mismatch.ResponseCommand)
+	}
+	assert.True(t, IsTransient(err))
+	assert.Equal(t, StateDisconnected, cli.State())
+}
@@ -0,0 +1,91 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/cyclegen-community/tdx-go/proto"
+	"io"
+	"net"
+	"syscall"
+)
+
+var (
+	// ErrClientClosed 客户端已被关闭
+	ErrClientClosed = errors.New("客户端已关闭")
+	// ErrDial 无法连接到服务器
+	ErrDial = errors.New("连接服务器失败")
+	// ErrTimeout 连接或读写超时
+	ErrTimeout = errors.New("请求超时")
+	// ErrShortWrite 请求数据未完整发送
+	ErrShortWrite = errors.New("数据未完整发送")
+	// ErrConnClosed 连接已被对端关闭或重置
+	ErrConnClosed = errors.New("连接已断开")
+	// ErrRateLimited 限流额度不足，且ctx的截止时间内无法取得令牌
+	ErrRateLimited = errors.New("请求过于频繁")
+	// ErrRateLimitConflict 同一服务器已按不同的配置创建了共享限流器
+	ErrRateLimitConflict = errors.New("服务器已使用不同的限流配置")
+	// ErrFrameTooLarge 响应包体超出MaxBodySize
+	ErrFrameTooLarge = errors.New("响应包体过大")
+	// ErrServerRejected 服务器返回空响应，通常表示指令不被支持或参数非法
+	ErrServerRejected = errors.New("服务器拒绝请求")
+	// ErrNoClients 连接池中没有可用的客户端
+	ErrNoClients = errors.New("连接池中没有可用的客户端")
+)
+
+// OpError 传输层错误，记录出错的操作与服务器地址
+type OpError struct {
+	// Op 出错的操作: dial, write, read
+	Op   string
+	Addr string
+	Err  error
+}
+
+func (e *OpError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Op, e.Addr, e.Err)
+}
+
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
+
+// Is 使errors.Is可按ErrDial、ErrTimeout、ErrConnClosed归类
+func (e *OpError) Is(target error) bool {
+	switch target {
+	case ErrDial:
+		return e.Op == "dial"
+	case ErrTimeout:
+		return e.Timeout()
+	case ErrConnClosed:
+		return errors.Is(e.Err, io.EOF) ||
+			errors.Is(e.Err, io.ErrUnexpectedEOF) ||
+			errors.Is(e.Err, syscall.ECONNRESET) ||
+			errors.Is(e.Err, syscall.EPIPE)
+	}
+	return false
+}
+
+// Timeout 实现net.Error
+func (e *OpError) Timeout() bool {
+	var netErr net.Error
+	if errors.As(e.Err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(e.Err, context.DeadlineExceeded)
+}
+
+// Temporary 实现net.Error，传输层错误在重连后均可能恢复
+func (e *OpError) Temporary() bool {
+	return true
+}
+
+// IsTransient 判断错误是否源于连接或数据流，可在重连或更换服务器后重试
+// 解析失败与服务器拒绝属于确定性错误，重试无意义
+func IsTransient(err error) bool {
+	var opErr *OpError
+	return errors.As(err, &opErr) ||
+		errors.Is(err, ErrConnClosed) ||
+		errors.Is(err, proto.ErrBadHeader) ||
+		errors.Is(err, proto.ErrMismatch) ||
+		errors.Is(err, proto.ErrDecompress)
+}
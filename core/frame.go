@@ -0,0 +1,116 @@
+package core
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/cyclegen-community/tdx-go/proto"
+	"github.com/cyclegen-community/tdx-go/utils"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// 默认的响应包体长度上限，即包头中16位长度字段的最大值
+// 协议本身保证包体不超过该值，因此默认不会拒绝任何响应，仅在通过WithMaxBodySize设置更小的上限时生效
+// 现有指令的响应包体远小于该值，如每页1000只证券的列表不足30KB，可据此设置上限以约束单个响应占用的内存
+const defaultMaxBodySize = 0xffff
+
+// frameReader 从连接中读取完整的响应帧，每个连接持有一个实例
+// 包头非法、序号不符、解压失败都意味着数据流已不可信，调用方需丢弃连接，
+// 只有超出长度上限的帧会被整帧跳过，连接仍可继续使用
+type frameReader struct {
+	r           io.Reader
+	addr        string
+	maxBodySize int
+	// dump 不为空时输出收到的原始数据
+	dump func(label string, data []byte)
+	// header 最近一次读取的响应包头，读取失败时可能为零值
+	header proto.PacketHeader
+	// 包头读取缓冲区，随frameReader复用
+	head [proto.PacketHeaderSize]byte
+}
+
+// readFrame 读取一个响应帧，返回解压后的包体，包头保存在fr.header中
+// 包体取自缓冲池，使用完毕后需通过putBuffer归还；reqHeader不为空时校验响应的序号与指令号
+func (fr *frameReader) readFrame(reqHeader *proto.RequestHeader) (*buffer, error) {
+	header := &fr.header
+	*header = proto.PacketHeader{}
+	if _, err := io.ReadFull(fr.r, fr.head[:]); err != nil {
+		return nil, &OpError{Op: "read", Addr: fr.addr, Err: err}
+	}
+	if fr.dump != nil {
+		fr.dump("包头", fr.head[:])
+	}
+	if err := header.Unmarshal(fr.head[:]); err != nil {
+		return nil, err
+	}
+	if reqHeader != nil {
+		if err := header.Match(reqHeader); err != nil {
+			return nil, err
+		}
+	}
+	if header.ZipSize > fr.maxBodySize || header.UnzipSize > fr.maxBodySize {
+		// 跳过整帧使数据流重新对齐到下一个包头
+		if _, err := io.CopyN(ioutil.Discard, fr.r, int64(header.ZipSize)); err != nil {
+			return nil, &OpError{Op: "read", Addr: fr.addr, Err: err}
+		}
+		return nil, fmt.Errorf("%w: 包体长度%d/%d超出上限%d",
+			ErrFrameTooLarge, header.ZipSize, header.UnzipSize, fr.maxBodySize)
+	}
+	body := getBuffer(header.ZipSize)
+	if _, err := io.ReadFull(fr.r, body.b); err != nil {
+		putBuffer(body)
+		return nil, &OpError{Op: "read", Addr: fr.addr, Err: err}
+	}
+	if header.Compressed() {
+		zipped := body
+		body = getBuffer(header.UnzipSize)
+		err := utils.ZlibUnCompressTo(body.b, zipped.b)
+		putBuffer(zipped)
+		if err != nil {
+			putBuffer(body)
+			return nil, fmt.Errorf("%w: %v", proto.ErrDecompress, err)
+		}
+	}
+	if fr.dump != nil {
+		fr.dump("包体", body.b)
+	}
+	return body, nil
+}
+
+// buffer 可复用的包体缓冲区
+type buffer struct {
+	b []byte
+}
+
+var buffers = sync.Pool{
+	New: func() interface{} {
+		return new(buffer)
+	},
+}
+
+// getBuffer 从缓冲池取出长度为size的缓冲区
+func getBuffer(size int) *buffer {
+	buf := buffers.Get().(*buffer)
+	if cap(buf.b) < size {
+		buf.b = make([]byte, size)
+	}
+	buf.b = buf.b[:size]
+	return buf
+}
+
+// putBuffer 归还缓冲区，归还后不得再访问其内容
+func putBuffer(buf *buffer) {
+	buffers.Put(buf)
+}
+
+// dumper 调试模式下输出收到的原始数据
+func (cli *Client) dumper() func(label string, data []byte) {
+	if !cli.debug {
+		return nil
+	}
+	addr := cli.Addr()
+	return func(label string, data []byte) {
+		cli.logger.Debugf("收到%s%s:\n%s", addr, label, hex.Dump(data))
+	}
+}
@@ -0,0 +1,104 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/cyclegen-community/tdx-go/proto"
+	"github.com/cyclegen-community/tdx-go/utils"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// buildFrame 构造响应帧，body为传输的包体，unzipSize为包头声明的解压后长度
+func buildFrame(prefix uint32, body []byte, unzipSize int) []byte {
+	frame := make([]byte, proto.PacketHeaderSize, proto.PacketHeaderSize+len(body))
+	binary.LittleEndian.PutUint32(frame, prefix)
+	binary.LittleEndian.PutUint16(frame[12:], uint16(len(body)))
+	binary.LittleEndian.PutUint16(frame[14:], uint16(unzipSize))
+	return append(frame, body...)
+}
+
+func TestFrameReader(t *testing.T) {
+	plain := bytes.Repeat([]byte("tdx"), 100)
+	zipped, _ := utils.ZlibCompress(plain)
+
+	var stream bytes.Buffer
+	stream.Write(buildFrame(proto.ResponsePrefix, zipped, len(plain)))
+	// 超长的帧被跳过后，后续的帧仍可正常读取
+	stream.Write(buildFrame(proto.ResponsePrefix, make([]byte, 500), 500))
+	stream.Write(buildFrame(proto.ResponsePrefix, []byte{1, 2, 3}, 3))
+	// 解压后的长度与包头不符
+	stream.Write(buildFrame(proto.ResponsePrefix, zipped, len(plain)-1))
+	fr := &frameReader{r: &stream, maxBodySize: 400}
+
+	body, err := fr.readFrame(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, plain, body.b)
+	assert.Equal(t, len(zipped), fr.header.ZipSize)
+	putBuffer(body)
+
+	_, err = fr.readFrame(nil)
+	assert.True(t, errors.Is(err, ErrFrameTooLarge))
+
+	body, err = fr.readFrame(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{1, 2, 3}, body.b)
+	putBuffer(body)
+
+	_, err = fr.readFrame(nil)
+	assert.True(t, errors.Is(err, proto.ErrDecompress))
+
+	// 数据流已读完
+	_, err = fr.readFrame(nil)
+	assert.True(t, errors.Is(err, ErrConnClosed))
+}
+
+func TestFrameReader_BadPrefix(t *testing.T) {
+	fr := frameReader{
+		r:           bytes.NewReader(buildFrame(0x12345678, []byte{1}, 1)),
+		maxBodySize: defaultMaxBodySize,
+	}
+	_, err := fr.readFrame(nil)
+	assert.True(t, errors.Is(err, proto.ErrBadHeader))
+	assert.True(t, IsTransient(err))
+}
+
+func benchmarkFrameReader(b *testing.B, frame []byte) {
+	r := bytes.NewReader(frame)
+	fr := &frameReader{r: r, maxBodySize: defaultMaxBodySize}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Reset(frame)
+		body, err := fr.readFrame(nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		putBuffer(body)
+	}
+}
+
+func BenchmarkFrameReader_Plain(b *testing.B) {
+	benchmarkFrameReader(b, buildFrame(proto.ResponsePrefix, bytes.Repeat([]byte("tdx"), 300), 900))
+}
+
+func BenchmarkFrameReader_Compressed(b *testing.B) {
+	plain := bytes.Repeat([]byte("tdx"), 3000)
+	zipped, _ := utils.ZlibCompress(plain)
+	benchmarkFrameReader(b, buildFrame(proto.ResponsePrefix, zipped, len(plain)))
+}
+
+func TestFrameReader_DefaultMaxBodySize(t *testing.T) {
+	// 默认上限即协议允许的最大长度，不会拒绝任何响应
+	body := make([]byte, 0xffff)
+	fr := &frameReader{r: bytes.NewReader(buildFrame(proto.ResponsePrefix, body, len(body))), maxBodySize: defaultMaxBodySize}
+	buf, err := fr.readFrame(nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, len(body), len(buf.b))
+		putBuffer(buf)
+	}
+
+	cli := newClient("127.0.0.1", 7709, WithMaxBodySize(0))
+	assert.Equal(t, defaultMaxBodySize, cli.MaxBodySize)
+}
@@ -0,0 +1,41 @@
+package core
+
+import (
+	"context"
+	"github.com/cyclegen-community/tdx-go/proto"
+	"github.com/cyclegen-community/tdx-go/proto/v1"
+	"time"
+)
+
+// 建立连接后需要依次发送的握手指令
+var handshakeFactories = []proto.Factory{
+	func() (req proto.Marshaler, resp proto.Unmarshaler, err error) {
+		req, resp, err = v1.NewSetupCmd1()
+		return
+	},
+	func() (req proto.Marshaler, resp proto.Unmarshaler, err error) {
+		req, resp, err = v1.NewSetupCmd2()
+		return
+	},
+	func() (req proto.Marshaler, resp proto.Unmarshaler, err error) {
+		req, resp, err = v1.NewSetupCmd3()
+		return
+	},
+}
+
+// handshake 在当前连接上完成SetupCmd1/2/3握手
+func (cli *Client) handshake(ctx context.Context) error {
+	for _, factory := range handshakeFactories {
+		req, resp, err := factory()
+		if err != nil {
+			return err
+		}
+		start := time.Now()
+		_, err = cli.roundTrip(ctx, req, resp)
+		cli.metrics.recordRequest(cli.Addr(), proto.CommandName(req), time.Since(start), false, err)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
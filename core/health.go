@@ -0,0 +1,154 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/cyclegen-community/tdx-go/proto/v1"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrBadData 服务器可以连接，但返回的数据明显无效，如证券数量为0或没有K线
+var ErrBadData = errors.New("服务器返回的数据无效")
+
+// DefaultHealthMaxAge 最新日线距今的最长时间，超出时视为数据停止更新
+// 需跨过春节、国庆等长假，因此远大于一个交易日
+const DefaultHealthMaxAge = 10 * 24 * time.Hour
+
+// 体检时查询的指数，上证指数在所有行情服务器上都有日线
+const (
+	healthMarket = v1.MarketShangHai
+	healthCode   = "000001"
+)
+
+// HealthReport 单台服务器的协议级体检结果
+type HealthReport struct {
+	Addr string `json:"addr"`
+	// Latency 连接、握手、查询证券数量与查询日线的总耗时
+	Latency       time.Duration `json:"latency"`
+	SecurityCount int           `json:"security_count"`
+	// LatestBar 服务器上最新一根指数日线的时间
+	LatestBar time.Time `json:"latest_bar"`
+	// Stale 最新日线早于其他服务器或超出DefaultHealthMaxAge，说明行情数据滞后
+	Stale bool  `json:"stale"`
+	Err   error `json:"-"`
+}
+
+// Healthy 体检成功且数据未滞后
+func (r HealthReport) Healthy() bool {
+	return r.Err == nil && !r.Stale
+}
+
+// ProbeHealth 以真实请求检查服务器，能连通而数据陈旧或异常的服务器同样会被发现
+// 依次完成连接与握手、查询上海市场证券数量、获取一根上证指数日线，opts用于设置超时、代理等
+// 连接失败时不重试，重试会掩盖线路的真实质量
+func ProbeHealth(ctx context.Context, addr string, opts ...Option) HealthReport {
+	report := HealthReport{Addr: addr}
+	start := time.Now()
+	// 调用方的opts可能在多个协程中共用，追加到副本上
+	cli, err := Dial(ctx, addr, append(opts[:len(opts):len(opts)], WithRetries(0), WithHandshake(true))...)
+	if err != nil {
+		report.Err = err
+		return report
+	}
+	defer cli.Close()
+
+	countReq, countResp, _ := v1.NewGetSecurityCount(healthMarket)
+	if err := cli.DoContext(ctx, countReq, countResp); err != nil {
+		report.Err = err
+		return report
+	}
+	report.SecurityCount = int(countResp.Count)
+
+	barsReq, barsResp, _ := v1.NewGetIndexBars(v1.KLineDay, healthMarket, healthCode, 0, 1)
+	if err := cli.DoContext(ctx, barsReq, barsResp); err != nil {
+		report.Err = err
+		return report
+	}
+	report.Latency = time.Since(start)
+
+	if report.SecurityCount == 0 || len(barsResp.Bars) == 0 {
+		report.Err = fmt.Errorf("%w: 证券数量%d, K线%d根", ErrBadData, report.SecurityCount, len(barsResp.Bars))
+		return report
+	}
+	report.LatestBar = barsResp.Bars[len(barsResp.Bars)-1].DateTime
+	report.Stale = time.Since(report.LatestBar) > DefaultHealthMaxAge
+	return report
+}
+
+// 同时体检的服务器数
+const healthConcurrency = 32
+
+// CheckHealth 并行体检addrs中的每台服务器，结果按RankHealth排序
+func CheckHealth(ctx context.Context, addrs []string, opts ...Option) []HealthReport {
+	return CheckHealthFunc(ctx, addrs, func(string) ([]Option, error) {
+		return opts, nil
+	})
+}
+
+// CheckHealthFunc 与CheckHealth相同，每台服务器的配置由optsFor返回，可为不同的服务器设置不同的代理
+// optsFor返回错误时不体检该服务器，错误记入其结果
+func CheckHealthFunc(ctx context.Context, addrs []string, optsFor func(addr string) ([]Option, error)) []HealthReport {
+	reports := make([]HealthReport, len(addrs))
+	indexes := make(chan int)
+	workers := healthConcurrency
+	if len(addrs) < workers {
+		workers = len(addrs)
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range indexes {
+				opts, err := optsFor(addrs[idx])
+				if err != nil {
+					reports[idx] = HealthReport{Addr: addrs[idx], Err: err}
+					continue
+				}
+				reports[idx] = ProbeHealth(ctx, addrs[idx], opts...)
+			}
+		}()
+	}
+	for idx := range addrs {
+		indexes <- idx
+	}
+	close(indexes)
+	wg.Wait()
+	return RankHealth(reports)
+}
+
+// RankHealth 将最新日线落后于其他服务器的结果标记为滞后，并按健康程度排序
+// 依次为健康的服务器、数据滞后的服务器、体检失败的服务器，同类中按延迟从低到高排列
+// 节假日所有服务器的日线同样停在最后一个交易日，以最新的服务器为基准不会误判
+func RankHealth(reports []HealthReport) []HealthReport {
+	var latest time.Time
+	for _, r := range reports {
+		if r.Err == nil && r.LatestBar.After(latest) {
+			latest = r.LatestBar
+		}
+	}
+	for idx := range reports {
+		if reports[idx].Err == nil && reports[idx].LatestBar.Before(latest) {
+			reports[idx].Stale = true
+		}
+	}
+	rank := func(r HealthReport) int {
+		switch {
+		case r.Err != nil:
+			return 2
+		case r.Stale:
+			return 1
+		}
+		return 0
+	}
+	sort.SliceStable(reports, func(i, j int) bool {
+		if ri, rj := rank(reports[i]), rank(reports[j]); ri != rj {
+			return ri < rj
+		}
+		return reports[i].Latency < reports[j].Latency
+	})
+	return reports
+}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"github.com/cyclegen-community/tdx-go/proto"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// healthHandler 返回指定的证券数量，指数日线的日期为day
+func healthHandler(count uint16, day time.Time) func(cmd uint16, body []byte) ([]byte, bool) {
+	return func(cmd uint16, body []byte) ([]byte, bool) {
+		w := proto.NewWriter(nil)
+		switch cmd {
+		case 0x044e:
+			w.Uint16(count)
+		case 0x052d:
+			w.Uint16(1)
+			w.Uint32(uint32(day.Year()*10000 + int(day.Month())*100 + day.Day()))
+			for _, v := range []int{3000000, 0, 0, 0} {
+				w.VarInt(v)
+			}
+			w.Raw(make([]byte, 12))
+		default:
+			return body, true
+		}
+		return w.Bytes(), true
+	}
+}
+
+func fakeServerAddr(srv *fakeServer) string {
+	host, port := srv.addr()
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+func TestProbeHealth(t *testing.T) {
+	today := time.Now()
+	addr := fakeServerAddr(newFakeServer(t, healthHandler(1500, today)))
+	report := ProbeHealth(context.Background(), addr)
+	assert.NoError(t, report.Err)
+	assert.True(t, report.Healthy())
+	assert.Equal(t, 1500, report.SecurityCount)
+	assert.Equal(t, today.Day(), report.LatestBar.Day())
+	assert.True(t, report.Latency > 0)
+
+	// 日线长期未更新
+	addr = fakeServerAddr(newFakeServer(t, healthHandler(1500, today.AddDate(0, -1, 0))))
+	report = ProbeHealth(context.Background(), addr)
+	assert.NoError(t, report.Err)
+	assert.True(t, report.Stale)
+
+	// 可以连接但没有数据
+	addr = fakeServerAddr(newFakeServer(t, healthHandler(0, today)))
+	report = ProbeHealth(context.Background(), addr)
+	assert.True(t, errors.Is(report.Err, ErrBadData))
+
+	// 握手后不作响应
+	addr = fakeServerAddr(newFakeServer(t, stallAfterHandshake))
+	report = ProbeHealth(context.Background(), addr, WithTimeout(50*time.Millisecond))
+	assert.True(t, errors.Is(report.Err, ErrTimeout))
+
+	// 不在调用方opts的剩余容量中追加配置
+	opts := make([]Option, 1, 4)
+	opts[0] = WithTimeout(50 * time.Millisecond)
+	ProbeHealth(context.Background(), addr, opts...)
+	assert.Nil(t, opts[:2][1])
+}
+
+func TestCheckHealth(t *testing.T) {
+	today := time.Now()
+	fresh := fakeServerAddr(newFakeServer(t, healthHandler(1500, today)))
+	lagging := fakeServerAddr(newFakeServer(t, healthHandler(1500, today.AddDate(0, 0, -1))))
+	reports := CheckHealth(context.Background(), []string{"127.0.0.1:1", lagging, fresh})
+	if !assert.Len(t, reports, 3) {
+		return
+	}
+	assert.Equal(t, fresh, reports[0].Addr)
+	assert.True(t, reports[0].Healthy())
+	// 落后于其他服务器一个交易日即视为滞后
+	assert.Equal(t, lagging, reports[1].Addr)
+	assert.True(t, reports[1].Stale)
+	assert.Error(t, reports[2].Err)
+}
+
+func TestCheckHealthFunc(t *testing.T) {
+	fresh := fakeServerAddr(newFakeServer(t, healthHandler(1500, time.Now())))
+	errBadProxy := errors.New("bad proxy")
+	var calls int32
+	addrs := []string{fresh, "127.0.0.1:1"}
+	reports := CheckHealthFunc(context.Background(), addrs, func(addr string) ([]Option, error) {
+		atomic.AddInt32(&calls, 1)
+		if addr == "127.0.0.1:1" {
+			return nil, errBadProxy
+		}
+		return []Option{WithTimeout(time.Second)}, nil
+	})
+	assert.Equal(t, int32(2), calls)
+	if assert.Len(t, reports, 2) {
+		assert.True(t, reports[0].Healthy())
+		assert.Equal(t, "127.0.0.1:1", reports[1].Addr)
+		assert.Equal(t, errBadProxy, reports[1].Err)
+	}
+}
+
+func TestRankHealth(t *testing.T) {
+	day := time.Date(2020, 10, 16, 15, 0, 0, 0, time.UTC)
+	reports := RankHealth([]HealthReport{
+		{Addr: "a", Latency: 30 * time.Millisecond, LatestBar: day},
+		{Addr: "b", Err: ErrDial},
+		{Addr: "c", Latency: 10 * time.Millisecond, LatestBar: day.AddDate(0, 0, -3)},
+		{Addr: "d", Latency: 20 * time.Millisecond, LatestBar: day},
+	})
+	var addrs []string
+	for _, r := range reports {
+		addrs = append(addrs, r.Addr)
+	}
+	assert.Equal(t, []string{"d", "a", "c", "b"}, addrs)
+	assert.True(t, reports[2].Stale)
+}
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"context"
+	"github.com/cyclegen-community/tdx-go/proto/v1"
+	"time"
+)
+
+// StartHeartbeat 启动后台心跳协程，连接空闲超过interval时发送心跳包
+// 心跳失败时丢弃当前连接，下一次请求会重新建立连接
+func (cli *Client) StartHeartbeat(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	if cli.heartbeatStop != nil || cli.state == StateClosed {
+		return
+	}
+	stop := make(chan struct{})
+	cli.heartbeatStop = stop
+	go cli.heartbeatLoop(interval, stop)
+}
+
+// StopHeartbeat 停止后台心跳协程
+func (cli *Client) StopHeartbeat() {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	if cli.heartbeatStop != nil {
+		close(cli.heartbeatStop)
+		cli.heartbeatStop = nil
+	}
+}
+
+func (cli *Client) heartbeatLoop(interval time.Duration, stop chan struct{}) {
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+		}
+		timer.Reset(cli.heartbeat(interval))
+	}
+}
+
+// heartbeat 连接空闲达到interval时发送一次心跳，返回距下一次检查的等待时间
+func (cli *Client) heartbeat(interval time.Duration) time.Duration {
+	// 连接正被其他请求占用，说明并不空闲
+	if !cli.tryAcquire() {
+		return interval
+	}
+	defer cli.release()
+	cli.mu.Lock()
+	state, conn, idle := cli.state, cli.conn, time.Since(cli.lastActive)
+	cli.mu.Unlock()
+	// 未连接时无需保活，由下一次请求负责重连
+	if state != StateConnected || conn == nil {
+		return interval
+	}
+	if idle < interval {
+		return interval - idle
+	}
+	req, resp, err := v1.NewHeartbeat()
+	if err != nil {
+		return interval
+	}
+	timeout := cli.Timeout
+	if timeout <= 0 {
+		timeout = interval
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	start := time.Now()
+	broken, err := cli.roundTrip(ctx, req, resp)
+	cli.metrics.recordRequest(cli.Addr(), req.Command(), time.Since(start), false, err)
+	if err != nil {
+		cli.logger.Warnf("心跳失败: %v", err)
+		if !broken {
+			cli.disconnect(conn)
+		}
+	}
+	return interval
+}
@@ -0,0 +1,208 @@
+package core
+
+import (
+	"context"
+	"github.com/cyclegen-community/tdx-go/proto"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HedgePolicy 对冲请求策略
+// 只读请求在等待超过历史延迟的Percentile分位后仍未响应时，向另一台服务器发送相同的请求，
+// 先返回的响应胜出，另一个请求在DrainTimeout内继续完成后丢弃其响应，超时则被取消
+type HedgePolicy struct {
+	// Percentile 以该分位的历史延迟作为对冲等待时间，取值(0, 1]，超出范围时按边界处理
+	Percentile float64
+	// MinDelay 对冲等待时间下限，避免在正常抖动范围内重复发送
+	MinDelay time.Duration
+	// MaxDelay 对冲等待时间上限，样本不足时使用该值
+	MaxDelay time.Duration
+	// MinSamples 计算分位所需的最少样本数
+	MinSamples int
+	// DrainTimeout 胜出的响应返回后，落选的请求继续完成的最长时间，超时后取消，不大于0时使用defaultHedgeDrainTimeout
+	// 让落选的请求完成可避免中途取消而丢弃连接
+	DrainTimeout time.Duration
+}
+
+// 落选请求默认的最长完成时间
+const defaultHedgeDrainTimeout = 2 * time.Second
+
+// DefaultHedgePolicy 在P95延迟后对冲，等待时间介于10ms与500ms之间
+var DefaultHedgePolicy = HedgePolicy{
+	Percentile:   0.95,
+	MinDelay:     10 * time.Millisecond,
+	MaxDelay:     500 * time.Millisecond,
+	MinSamples:   20,
+	DrainTimeout: defaultHedgeDrainTimeout,
+}
+
+// 每个指令保留的延迟样本数
+const latencyWindowSize = 128
+
+// latencyWindow 最近若干次成功请求的延迟
+type latencyWindow struct {
+	samples [latencyWindowSize]time.Duration
+	n       int
+	next    int
+}
+
+func (w *latencyWindow) add(d time.Duration) {
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % latencyWindowSize
+	if w.n < latencyWindowSize {
+		w.n++
+	}
+}
+
+func (w *latencyWindow) percentile(p float64) time.Duration {
+	sorted := make([]time.Duration, w.n)
+	copy(sorted, w.samples[:w.n])
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	idx := int(math.Ceil(p*float64(w.n))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx > w.n-1 {
+		idx = w.n - 1
+	}
+	return sorted[idx]
+}
+
+// observe 记录一次成功请求的延迟
+func (hub *Hub) observe(command string, d time.Duration) {
+	hub.latencyMu.Lock()
+	defer hub.latencyMu.Unlock()
+	if hub.latencies == nil {
+		hub.latencies = make(map[string]*latencyWindow)
+	}
+	w, ok := hub.latencies[command]
+	if !ok {
+		w = new(latencyWindow)
+		hub.latencies[command] = w
+	}
+	w.add(d)
+}
+
+// hedgeDelay 计算指令的对冲等待时间
+func (hub *Hub) hedgeDelay(command string, policy *HedgePolicy) time.Duration {
+	hub.latencyMu.Lock()
+	w := hub.latencies[command]
+	delay := policy.MaxDelay
+	if w != nil && w.n >= policy.MinSamples && w.n > 0 {
+		delay = w.percentile(policy.Percentile)
+	}
+	hub.latencyMu.Unlock()
+	if delay < policy.MinDelay {
+		delay = policy.MinDelay
+	}
+	if policy.MaxDelay > 0 && delay > policy.MaxDelay {
+		delay = policy.MaxDelay
+	}
+	return delay
+}
+
+type hedgeResult struct {
+	resp proto.Unmarshaler
+	err  error
+}
+
+// detachedContext 保留父ctx的值与截止时间，但不随父ctx取消
+// DoHedged返回后落选的请求在其中继续完成，截止时间与HedgePolicy.DrainTimeout约束其最长耗时
+type detachedContext struct {
+	parent context.Context
+}
+
+func (c detachedContext) Deadline() (time.Time, bool) { return c.parent.Deadline() }
+func (detachedContext) Done() <-chan struct{}         { return nil }
+func (detachedContext) Err() error                    { return nil }
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+// DoHedged 发送factory创建的请求并返回响应
+// 启用Hedge且请求可重放时，超过对冲等待时间未响应或首选服务器先行失败时，用factory创建新的请求发往另一台服务器，
+// 两个请求不共享请求与响应结构，返回先成功的响应；未启用时等同于DoContext
+// 落选的请求在DrainTimeout内完成时其连接仍可复用，超时后被取消；ctx在返回前结束时两个请求均被取消
+func (hub *Hub) DoHedged(ctx context.Context, factory proto.Factory) (proto.Unmarshaler, error) {
+	req, resp, err := factory()
+	if err != nil {
+		return nil, err
+	}
+	primary, err := hub.pick()
+	if err != nil {
+		return nil, err
+	}
+	policy := hub.Hedge
+	if policy == nil || !proto.IsIdempotent(req) {
+		return resp, hub.doOn(ctx, primary, req, resp)
+	}
+
+	reqCtx, cancel := context.WithCancel(detachedContext{ctx})
+	var wg sync.WaitGroup
+	// 返回后不会再发送新的请求，落选的请求超过DrainTimeout仍未完成时取消，全部完成后释放reqCtx
+	defer func() {
+		drainTimeout := policy.DrainTimeout
+		if drainTimeout <= 0 {
+			drainTimeout = defaultHedgeDrainTimeout
+		}
+		timer := time.AfterFunc(drainTimeout, cancel)
+		go func() {
+			wg.Wait()
+			timer.Stop()
+			cancel()
+		}()
+	}()
+	results := make(chan hedgeResult, 2)
+	send := func(cli *Client, req proto.Marshaler, resp proto.Unmarshaler) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results <- hedgeResult{resp: resp, err: hub.doOn(reqCtx, cli, req, resp)}
+		}()
+	}
+	send(primary, req, resp)
+	inflight := 1
+
+	timer := time.NewTimer(hub.hedgeDelay(proto.CommandName(req), policy))
+	defer timer.Stop()
+	hedged := false
+	hedge := func() {
+		hedged = true
+		req, resp, err := factory()
+		if err != nil {
+			return
+		}
+		secondary := hub.pickOther(primary)
+		if secondary == nil {
+			return
+		}
+		send(secondary, req, resp)
+		inflight++
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			cancel()
+			return nil, ctx.Err()
+		case <-timer.C:
+			if !hedged {
+				hedge()
+			}
+		case result := <-results:
+			inflight--
+			if result.err == nil {
+				return result.resp, nil
+			}
+			// 首选服务器在对冲等待时间内失败，立即改发另一台服务器
+			if !hedged {
+				hedge()
+			}
+			if inflight == 0 {
+				return nil, result.err
+			}
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package core
+
+import (
+	"context"
+	"github.com/cyclegen-community/tdx-go/proto"
+	"github.com/cyclegen-community/tdx-go/proto/v1"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// delayHandler 查询股票数量请求延迟delay后才响应
+func delayHandler(delay time.Duration, counter *int32) func(cmd uint16, body []byte) ([]byte, bool) {
+	return func(cmd uint16, body []byte) ([]byte, bool) {
+		if cmd == 0x044e {
+			atomic.AddInt32(counter, 1)
+			time.Sleep(delay)
+		}
+		return body, true
+	}
+}
+
+func countFactory() (req proto.Marshaler, resp proto.Unmarshaler, err error) {
+	req, resp, err = v1.NewGetSecurityCount(v1.MarketShangHai)
+	return
+}
+
+// 不可重放的请求
+type nonIdempotentRequest struct {
+	v1.GetSecurityCountRequest
+}
+
+func (req *nonIdempotentRequest) Idempotent() bool {
+	return false
+}
+
+func TestHub_DoHedged(t *testing.T) {
+	var slow, fast int32
+	hub := NewHub(
+		NewClient(newFakeServer(t, delayHandler(300*time.Millisecond, &slow)).addr()),
+		NewClient(newFakeServer(t, delayHandler(0, &fast)).addr()),
+	)
+	defer hub.Close()
+	policy := DefaultHedgePolicy
+	policy.MaxDelay = 30 * time.Millisecond
+	hub.Hedge = &policy
+
+	// 无论首选哪台服务器，都应在慢速服务器响应前得到结果
+	for i := 0; i < 4; i++ {
+		start := time.Now()
+		resp, err := hub.DoHedged(context.Background(), countFactory)
+		if assert.NoError(t, err) {
+			assert.Equal(t, uint(v1.MarketShangHai), resp.(*v1.GetSecurityCountResponse).Count)
+		}
+		assert.True(t, time.Since(start) < 200*time.Millisecond)
+	}
+	assert.Equal(t, int32(4), atomic.LoadInt32(&fast))
+}
+
+func TestHub_DoHedgedNonIdempotent(t *testing.T) {
+	var count int32
+	hub := NewHub(
+		NewClient(newFakeServer(t, delayHandler(50*time.Millisecond, &count)).addr()),
+		NewClient(newFakeServer(t, delayHandler(50*time.Millisecond, &count)).addr()),
+	)
+	defer hub.Close()
+	policy := DefaultHedgePolicy
+	policy.MaxDelay = 10 * time.Millisecond
+	hub.Hedge = &policy
+
+	_, err := hub.DoHedged(context.Background(), countFactory)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&count))
+
+	// 不可重放的请求不会被对冲
+	_, err = hub.DoHedged(context.Background(), func() (proto.Marshaler, proto.Unmarshaler, error) {
+		req, resp, err := v1.NewGetSecurityCount(v1.MarketShangHai)
+		return &nonIdempotentRequest{*req}, resp, err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&count))
+}
+
+func TestHub_HedgeDelay(t *testing.T) {
+	hub := NewHub()
+	policy := DefaultHedgePolicy
+	assert.Equal(t, policy.MaxDelay, hub.hedgeDelay("GetSecurityCount", &policy))
+	for i := 1; i <= 100; i++ {
+		hub.observe("GetSecurityCount", time.Duration(i)*time.Millisecond)
+	}
+	assert.Equal(t, 95*time.Millisecond, hub.hedgeDelay("GetSecurityCount", &policy))
+	policy.MaxDelay = 50 * time.Millisecond
+	assert.Equal(t, 50*time.Millisecond, hub.hedgeDelay("GetSecurityCount", &policy))
+
+	// 超出(0, 1]的分位按边界处理
+	policy.MaxDelay = time.Second
+	policy.Percentile = 95
+	assert.Equal(t, 100*time.Millisecond, hub.hedgeDelay("GetSecurityCount", &policy))
+	policy.Percentile = -1
+	assert.Equal(t, policy.MinDelay, hub.hedgeDelay("GetSecurityCount", &policy))
+}
+
+func TestHub_DoHedgedDrainTimeout(t *testing.T) {
+	var fast int32
+	stalled := NewClient(newFakeServer(t, stallAfterHandshake).addr())
+	stalled.Timeout = 0
+	hub := NewHub(stalled, NewClient(newFakeServer(t, delayHandler(0, &fast)).addr()))
+	defer hub.Close()
+	policy := DefaultHedgePolicy
+	policy.MaxDelay = 10 * time.Millisecond
+	policy.DrainTimeout = 50 * time.Millisecond
+	hub.Hedge = &policy
+
+	// 落选的请求没有超时也会在DrainTimeout后被取消，不会一直占用连接
+	for i := 0; i < 2; i++ {
+		_, err := hub.DoHedged(context.Background(), countFactory)
+		assert.NoError(t, err)
+	}
+	done := make(chan struct{})
+	go func() {
+		stalled.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("落选的请求未被取消")
+	}
+}
+
+func TestHub_DoHedgedLoserKeepsConnection(t *testing.T) {
+	var slow, fast int32
+	slowCli := NewClient(newFakeServer(t, delayHandler(100*time.Millisecond, &slow)).addr())
+	hub := NewHub(slowCli, NewClient(newFakeServer(t, delayHandler(0, &fast)).addr()))
+	defer hub.Close()
+	policy := DefaultHedgePolicy
+	policy.MaxDelay = 10 * time.Millisecond
+	hub.Hedge = &policy
+
+	// 轮流首选两台服务器，首选慢速服务器时对冲请求胜出，慢速请求不会被取消
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		_, err := hub.DoHedged(ctx, countFactory)
+		cancel()
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&slow))
+	slowCli.inflight.Wait()
+	assert.Equal(t, StateConnected, slowCli.State())
+}
+
+func TestHub_DoHedgedPrimaryFailure(t *testing.T) {
+	var count int32
+	rejecting := newFakeServer(t, func(cmd uint16, body []byte) ([]byte, bool) {
+		if cmd == 0x044e {
+			return nil, true
+		}
+		return body, true
+	})
+	hub := NewHub(
+		NewClient(rejecting.addr()),
+		NewClient(newFakeServer(t, delayHandler(0, &count)).addr()),
+	)
+	defer hub.Close()
+	policy := DefaultHedgePolicy
+	policy.MinDelay = time.Second
+	policy.MaxDelay = time.Second
+	hub.Hedge = &policy
+
+	// 首选服务器立即失败时不等待对冲时间
+	for i := 0; i < 2; i++ {
+		start := time.Now()
+		_, err := hub.DoHedged(context.Background(), countFactory)
+		assert.NoError(t, err)
+		assert.True(t, time.Since(start) < 500*time.Millisecond)
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&count))
+}
@@ -1,13 +1,163 @@
 package core
 
-import "sync"
+import (
+	"context"
+	"github.com/cyclegen-community/tdx-go/proto"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
-// todo: 链接池，无感切换
+// Hub 连接池，将请求轮流分发到多个客户端
+// 单个Client同一时刻只处理一个请求，并行请求需要多个连接，它们可以指向同一或不同的服务器
 type Hub struct {
-	lock    sync.Mutex
-	Clients []Client
+	mu      sync.RWMutex
+	clients []*Client
+	next    uint32
+
+	// Hedge 对冲请求策略，仅DoHedged与GetSecurityQuotes使用，Do与DoContext不对冲；为nil时不启用，需在使用连接池前设置
+	Hedge *HedgePolicy
+
+	latencyMu sync.Mutex
+	latencies map[string]*latencyWindow
+
+	rebalanceMu     sync.Mutex
+	rebalanceCancel context.CancelFunc
+	rebalanceDone   chan struct{}
+}
+
+// NewHub 使用已建立的客户端创建连接池
+func NewHub(clients ...*Client) *Hub {
+	return &Hub{clients: clients}
+}
+
+// DialHub 并行连接addrs中的每个地址，同一地址出现多次时建立多条连接
+// 部分地址连接失败时忽略，全部失败时返回最后一个错误
+func DialHub(ctx context.Context, addrs []string, opts ...Option) (*Hub, error) {
+	clients := make([]*Client, len(addrs))
+	errs := make([]error, len(addrs))
+	var wg sync.WaitGroup
+	for idx := range addrs {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			clients[idx], errs[idx] = Dial(ctx, addrs[idx], opts...)
+		}(idx)
+	}
+	wg.Wait()
+
+	hub := NewHub()
+	err := ErrNoClients
+	for idx, cli := range clients {
+		if errs[idx] != nil {
+			err = errs[idx]
+			continue
+		}
+		hub.Add(cli)
+	}
+	if hub.Len() == 0 {
+		return nil, err
+	}
+	return hub, nil
+}
+
+// Add 向连接池加入客户端
+func (hub *Hub) Add(cli *Client) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	hub.clients = append(hub.clients, cli)
+}
+
+// Clients 返回连接池中的客户端
+func (hub *Hub) Clients() []*Client {
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	return append([]*Client(nil), hub.clients...)
+}
+
+// Len 返回连接池中的客户端数量
+func (hub *Hub) Len() int {
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	return len(hub.clients)
+}
+
+// pick 轮流选择未关闭的客户端
+// 选中的客户端计入在途请求，调用方须经doOn发送请求，迁移时据此等待请求完成
+func (hub *Hub) pick() (*Client, error) {
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	n := len(hub.clients)
+	start := atomic.AddUint32(&hub.next, 1)
+	for i := 0; i < n; i++ {
+		cli := hub.clients[(int(start)+i)%n]
+		if cli.State() != StateClosed {
+			cli.inflight.Add(1)
+			return cli, nil
+		}
+	}
+	return nil, ErrNoClients
+}
+
+// pickOther 选择与primary不同的客户端，优先选择连接到其他服务器的客户端，选中的客户端同样计入在途请求
+func (hub *Hub) pickOther(primary *Client) *Client {
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	var fallback *Client
+	n := len(hub.clients)
+	start := int(atomic.LoadUint32(&hub.next))
+	for i := 0; i < n; i++ {
+		cli := hub.clients[(start+i)%n]
+		if cli == primary || cli.State() == StateClosed {
+			continue
+		}
+		if cli.Addr() != primary.Addr() {
+			cli.inflight.Add(1)
+			return cli
+		}
+		if fallback == nil {
+			fallback = cli
+		}
+	}
+	if fallback != nil {
+		fallback.inflight.Add(1)
+	}
+	return fallback
+}
+
+// Do 使用池中的某个客户端发送请求
+func (hub *Hub) Do(req proto.Marshaler, resp proto.Unmarshaler) error {
+	return hub.DoContext(context.Background(), req, resp)
+}
+
+// DoContext 使用池中的某个客户端发送请求，重试与超时由该客户端负责
+func (hub *Hub) DoContext(ctx context.Context, req proto.Marshaler, resp proto.Unmarshaler) error {
+	cli, err := hub.pick()
+	if err != nil {
+		return err
+	}
+	return hub.doOn(ctx, cli, req, resp)
+}
+
+// doOn 使用pick选中的客户端发送请求，成功时记录延迟供对冲策略使用
+func (hub *Hub) doOn(ctx context.Context, cli *Client, req proto.Marshaler, resp proto.Unmarshaler) error {
+	defer cli.inflight.Done()
+	start := time.Now()
+	err := cli.DoContext(ctx, req, resp)
+	if err == nil {
+		hub.observe(proto.CommandName(req), time.Since(start))
+	}
+	return err
 }
 
-func (hub *Hub) Do() error {
-	return nil
+// Close 停止后台重新排序并关闭池中所有客户端
+func (hub *Hub) Close() error {
+	hub.StopRebalance()
+	var err error
+	for _, cli := range hub.Clients() {
+		if e := cli.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
 }
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"github.com/cyclegen-community/tdx-go/proto/v1"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+// countingHandler 统计每个服务器收到的查询股票数量请求
+func countingHandler(counter *int32) func(cmd uint16, body []byte) ([]byte, bool) {
+	return func(cmd uint16, body []byte) ([]byte, bool) {
+		if cmd == 0x044e {
+			atomic.AddInt32(counter, 1)
+		}
+		return body, true
+	}
+}
+
+func TestHub_Do(t *testing.T) {
+	var counts [2]int32
+	var addrs []string
+	for idx := range counts {
+		host, port := newFakeServer(t, countingHandler(&counts[idx])).addr()
+		addrs = append(addrs, net.JoinHostPort(host, strconv.Itoa(port)))
+	}
+	// 无法连接的地址被忽略
+	hub, err := DialHub(context.Background(), append(addrs, "127.0.0.1:1"), WithRetries(0))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer hub.Close()
+	assert.Equal(t, 2, hub.Len())
+
+	for i := 0; i < 10; i++ {
+		req, resp, _ := v1.NewGetSecurityCount(v1.MarketShangHai)
+		assert.NoError(t, hub.Do(req, resp))
+	}
+	assert.Equal(t, [2]int32{5, 5}, counts)
+
+	// 已关闭的客户端不再被选中
+	hub.Clients()[0].Close()
+	for i := 0; i < 4; i++ {
+		req, resp, _ := v1.NewGetSecurityCount(v1.MarketShangHai)
+		assert.NoError(t, hub.Do(req, resp))
+	}
+	assert.Equal(t, [2]int32{5, 9}, counts)
+
+	hub.Close()
+	req, resp, _ := v1.NewGetSecurityCount(v1.MarketShangHai)
+	assert.True(t, errors.Is(hub.Do(req, resp), ErrNoClients))
+}
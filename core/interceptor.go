@@ -0,0 +1,66 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"github.com/cyclegen-community/tdx-go/proto"
+	"github.com/cyclegen-community/tdx-go/utils/logger"
+	"time"
+)
+
+// Invoker 执行一次请求
+type Invoker func(ctx context.Context, request proto.Marshaler, response proto.Unmarshaler) error
+
+// Interceptor 请求拦截器，包裹在Client.Do外层，调用next将请求交给下一环
+// 可用于日志、统计、缓存、限流、链路追踪等，指令名称可通过proto.CommandName获取
+type Interceptor func(ctx context.Context, request proto.Marshaler, response proto.Unmarshaler, next Invoker) error
+
+// chainInterceptors 将拦截器依次包裹在final外层，interceptors[0]位于最外层
+func chainInterceptors(interceptors []Interceptor, final Invoker) Invoker {
+	invoker := final
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoker
+		invoker = func(ctx context.Context, request proto.Marshaler, response proto.Unmarshaler) error {
+			return interceptor(ctx, request, response, next)
+		}
+	}
+	return invoker
+}
+
+// LoggingInterceptor 记录每次请求的指令名称、耗时与错误
+func LoggingInterceptor(l logger.Logger) Interceptor {
+	return func(ctx context.Context, request proto.Marshaler, response proto.Unmarshaler, next Invoker) error {
+		start := time.Now()
+		err := next(ctx, request, response)
+		if err != nil {
+			l.Warnf("%s 失败 耗时%v: %v", proto.CommandName(request), time.Since(start), err)
+		} else {
+			l.Infof("%s 完成 耗时%v", proto.CommandName(request), time.Since(start))
+		}
+		return err
+	}
+}
+
+// TimeoutInterceptor 为未设置截止时间的请求附加timeout，包含排队与重试的总耗时
+func TimeoutInterceptor(timeout time.Duration) Interceptor {
+	return func(ctx context.Context, request proto.Marshaler, response proto.Unmarshaler, next Invoker) error {
+		if _, ok := ctx.Deadline(); !ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return next(ctx, request, response)
+	}
+}
+
+// RecoverInterceptor 将序列化或解析过程中的panic转换为错误返回
+func RecoverInterceptor() Interceptor {
+	return func(ctx context.Context, request proto.Marshaler, response proto.Unmarshaler, next Invoker) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("%s panic: %v", proto.CommandName(request), r)
+			}
+		}()
+		return next(ctx, request, response)
+	}
+}
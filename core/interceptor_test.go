@@ -0,0 +1,54 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"github.com/cyclegen-community/tdx-go/proto"
+	"github.com/cyclegen-community/tdx-go/proto/v1"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestChainInterceptors(t *testing.T) {
+	var trace []string
+	record := func(name string) Interceptor {
+		return func(ctx context.Context, request proto.Marshaler, response proto.Unmarshaler, next Invoker) error {
+			trace = append(trace, name+">"+proto.CommandName(request))
+			err := next(ctx, request, response)
+			trace = append(trace, "<"+name)
+			return err
+		}
+	}
+	final := func(ctx context.Context, request proto.Marshaler, response proto.Unmarshaler) error {
+		trace = append(trace, "do")
+		panic("boom")
+	}
+	invoker := chainInterceptors([]Interceptor{record("a"), RecoverInterceptor(), record("b")}, final)
+
+	req, resp, _ := v1.NewHeartbeat()
+	err := invoker(context.Background(), req, resp)
+	assert.Error(t, err)
+	assert.Equal(t, []string{"a>Heartbeat", "b>Heartbeat", "do", "<a"}, trace)
+}
+
+func TestClient_Interceptors(t *testing.T) {
+	host, port := newFakeServer(t, echoHandler).addr()
+	var commands []string
+	cli := newClient(host, port, WithInterceptors(
+		func(ctx context.Context, request proto.Marshaler, response proto.Unmarshaler, next Invoker) error {
+			commands = append(commands, proto.CommandName(request))
+			return next(ctx, request, response)
+		},
+		func(ctx context.Context, request proto.Marshaler, response proto.Unmarshaler, next Invoker) error {
+			return errors.New("拦截")
+		},
+	))
+	defer cli.Close()
+
+	req, resp, _ := v1.NewGetSecurityCount(v1.MarketShangHai)
+	err := cli.Do(req, resp)
+	assert.EqualError(t, err, "拦截")
+	assert.Equal(t, []string{"GetSecurityCount"}, commands)
+	// 拦截器未调用next，请求不会发出
+	assert.Equal(t, StateDisconnected, cli.State())
+}
@@ -0,0 +1,275 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"github.com/cyclegen-community/tdx-go/proto"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultMetrics 默认的统计实例，所有未通过WithMetrics指定统计实例的客户端共用
+// 以"tdx"为名发布到expvar
+var DefaultMetrics = NewMetrics()
+
+func init() {
+	expvar.Publish("tdx", expvar.Func(func() interface{} {
+		return DefaultMetrics.Snapshot()
+	}))
+}
+
+// LatencyBuckets 耗时直方图各区间的上界
+var LatencyBuckets = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+}
+
+// Histogram 耗时直方图
+// Counts[i]为耗时不超过Bounds[i]的次数(不含更小区间)，最后一项为超过所有上界的次数
+type Histogram struct {
+	Bounds []time.Duration `json:"bounds"`
+	Counts []int64         `json:"counts"`
+	Count  int64           `json:"count"`
+	Sum    time.Duration   `json:"sum"`
+}
+
+func newHistogram() Histogram {
+	return Histogram{
+		Bounds: LatencyBuckets,
+		Counts: make([]int64, len(LatencyBuckets)+1),
+	}
+}
+
+func (h *Histogram) observe(d time.Duration) {
+	idx := sort.Search(len(h.Bounds), func(i int) bool {
+		return d <= h.Bounds[i]
+	})
+	h.Counts[idx]++
+	h.Count++
+	h.Sum += d
+}
+
+// Mean 平均耗时
+func (h Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// CommandStats 某一服务器上某一指令的统计
+// Requests、Errors、CacheHits与Latency按每次DoContext调用计一次，包含拦截器的耗时与结果，
+// Attempts、TransportErrors与流量按实际收发计，连接中断后的每次重试各计一次
+type CommandStats struct {
+	Server   string `json:"server"`
+	Command  string `json:"command"`
+	Requests int64  `json:"requests"`
+	// Errors 按错误类别计数请求的最终结果，类别见ErrorKind
+	Errors map[string]int64 `json:"errors"`
+	// CacheHits 由缓存直接返回、未发送到服务器的请求数
+	CacheHits int64 `json:"cache_hits"`
+	// Attempts 尝试收发的次数，含重连失败
+	Attempts int64 `json:"attempts"`
+	// TransportErrors 按错误类别计数每次收发中的传输层错误
+	TransportErrors map[string]int64 `json:"transport_errors"`
+	// BytesSent 发送的请求字节数
+	BytesSent int64 `json:"bytes_sent"`
+	// BytesReceived 接收的字节数，含包头，包体按压缩后的长度计
+	BytesReceived int64 `json:"bytes_received"`
+	// ZipBytes与UnzipBytes为响应包体压缩前后的总长度
+	ZipBytes   int64     `json:"zip_bytes"`
+	UnzipBytes int64     `json:"unzip_bytes"`
+	Latency    Histogram `json:"latency"`
+}
+
+// CompressionRatio 响应包体的压缩比(解压后长度/传输长度)
+func (s CommandStats) CompressionRatio() float64 {
+	if s.ZipBytes == 0 {
+		return 0
+	}
+	return float64(s.UnzipBytes) / float64(s.ZipBytes)
+}
+
+// Stats 统计快照
+type Stats struct {
+	Commands []CommandStats `json:"commands"`
+}
+
+// Metrics 按服务器与指令汇总请求次数、耗时、流量与错误
+type Metrics struct {
+	mu      sync.Mutex
+	entries map[metricsKey]*CommandStats
+}
+
+type metricsKey struct {
+	server  string
+	command string
+}
+
+// NewMetrics 创建统计实例
+func NewMetrics() *Metrics {
+	return &Metrics{entries: map[metricsKey]*CommandStats{}}
+}
+
+// requestTrace 随ctx在拦截器链中传递，记录拦截器对请求的处理
+type requestTrace struct {
+	cacheHit bool
+}
+
+type traceKey struct{}
+
+func withTrace(ctx context.Context, trace *requestTrace) context.Context {
+	return context.WithValue(ctx, traceKey{}, trace)
+}
+
+// markCacheHit 标记当前请求由缓存直接返回
+func markCacheHit(ctx context.Context) {
+	if trace, ok := ctx.Value(traceKey{}).(*requestTrace); ok {
+		trace.cacheHit = true
+	}
+}
+
+// sample 一次收发的采样数据
+type sample struct {
+	command   string
+	sent      int
+	zipSize   int
+	unzipSize int
+}
+
+// entry 返回server上command的统计项，不存在时创建，调用方需持有锁
+func (m *Metrics) entry(server, command string) *CommandStats {
+	key := metricsKey{server: server, command: command}
+	stats, ok := m.entries[key]
+	if !ok {
+		stats = &CommandStats{
+			Server:          server,
+			Command:         command,
+			Errors:          map[string]int64{},
+			TransportErrors: map[string]int64{},
+			Latency:         newHistogram(),
+		}
+		m.entries[key] = stats
+	}
+	return stats
+}
+
+// recordRequest 记录一次请求的耗时与最终结果，m为nil时不作统计
+func (m *Metrics) recordRequest(server, command string, latency time.Duration, cacheHit bool, err error) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := m.entry(server, command)
+	stats.Requests++
+	stats.Latency.observe(latency)
+	if cacheHit {
+		stats.CacheHits++
+	}
+	if err != nil {
+		stats.Errors[ErrorKind(err)]++
+	}
+}
+
+// recordAttempt 记录一次实际收发的流量，只统计传输层错误，m为nil时不作统计
+func (m *Metrics) recordAttempt(server string, smp *sample, err error) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := m.entry(server, smp.command)
+	stats.Attempts++
+	stats.BytesSent += int64(smp.sent)
+	if smp.zipSize > 0 || smp.unzipSize > 0 {
+		stats.BytesReceived += int64(proto.PacketHeaderSize + smp.zipSize)
+		stats.ZipBytes += int64(smp.zipSize)
+		stats.UnzipBytes += int64(smp.unzipSize)
+	}
+	if err != nil && (IsTransient(err) || errors.Is(err, ErrTimeout)) {
+		stats.TransportErrors[ErrorKind(err)]++
+	}
+}
+
+// Snapshot 返回当前统计数据的副本，按服务器与指令排序
+func (m *Metrics) Snapshot() Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	snapshot := Stats{Commands: make([]CommandStats, 0, len(m.entries))}
+	for _, stats := range m.entries {
+		cp := *stats
+		cp.Errors = copyCounts(stats.Errors)
+		cp.TransportErrors = copyCounts(stats.TransportErrors)
+		cp.Latency.Counts = append([]int64(nil), stats.Latency.Counts...)
+		snapshot.Commands = append(snapshot.Commands, cp)
+	}
+	sort.Slice(snapshot.Commands, func(i, j int) bool {
+		a, b := snapshot.Commands[i], snapshot.Commands[j]
+		if a.Server != b.Server {
+			return a.Server < b.Server
+		}
+		return a.Command < b.Command
+	})
+	return snapshot
+}
+
+func copyCounts(counts map[string]int64) map[string]int64 {
+	cp := make(map[string]int64, len(counts))
+	for kind, n := range counts {
+		cp[kind] = n
+	}
+	return cp
+}
+
+// Reset 清空统计数据
+func (m *Metrics) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = map[metricsKey]*CommandStats{}
+}
+
+// ErrorKind 返回错误的统计类别
+func ErrorKind(err error) string {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, ErrTimeout):
+		return "timeout"
+	case errors.Is(err, ErrCircuitOpen):
+		return "circuit_open"
+	case errors.Is(err, ErrDial):
+		return "dial"
+	case errors.Is(err, ErrShortWrite):
+		return "short_write"
+	case errors.Is(err, ErrConnClosed):
+		return "conn_closed"
+	case errors.Is(err, ErrClientClosed):
+		return "client_closed"
+	case errors.Is(err, ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, ErrFrameTooLarge):
+		return "frame_too_large"
+	case errors.Is(err, ErrServerRejected):
+		return "rejected"
+	case errors.Is(err, proto.ErrBadHeader):
+		return "bad_header"
+	case errors.Is(err, proto.ErrMismatch):
+		return "mismatch"
+	case errors.Is(err, proto.ErrDecompress):
+		return "decompress"
+	case errors.Is(err, proto.ErrDecode):
+		return "decode"
+	}
+	return "other"
+}
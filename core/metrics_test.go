@@ -0,0 +1,97 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"github.com/cyclegen-community/tdx-go/proto/v1"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	srv := newFakeServer(t, func(cmd uint16, body []byte) ([]byte, bool) {
+		if cmd == 0x0450 {
+			return nil, true
+		}
+		return body, true
+	})
+	host, port := srv.addr()
+	metrics := NewMetrics()
+	cli := newClient(host, port, WithMetrics(metrics))
+	defer cli.Close()
+
+	req, resp, _ := v1.NewGetSecurityCount(v1.MarketShangHai)
+	assert.NoError(t, cli.Do(req, resp))
+	assert.NoError(t, cli.Do(req, resp))
+	listReq, listResp, _ := v1.NewGetSecurityList(v1.MarketShangHai, 0)
+	assert.Error(t, cli.Do(listReq, listResp))
+
+	byCommand := map[string]CommandStats{}
+	for _, stats := range metrics.Snapshot().Commands {
+		assert.Equal(t, cli.Addr(), stats.Server)
+		byCommand[stats.Command] = stats
+	}
+	count := byCommand["GetSecurityCount"]
+	assert.Equal(t, int64(2), count.Requests)
+	assert.Equal(t, int64(2), count.Latency.Count)
+	assert.Equal(t, int64(2*18), count.BytesSent)
+	assert.Equal(t, int64(2*(16+6)), count.BytesReceived)
+	assert.Equal(t, 1.0, count.CompressionRatio())
+	assert.Equal(t, map[string]int64{"rejected": 1}, byCommand["GetSecurityList"].Errors)
+	assert.Equal(t, int64(1), byCommand["SetupCmd3"].Requests)
+	assert.Equal(t, int64(1), byCommand["SetupCmd3"].Attempts)
+
+	assert.True(t, strings.HasPrefix(expvar.Get("tdx").String(), `{"commands":`))
+}
+
+// 统计按请求计数，包含拦截器的结果，重试只计入收发次数
+func TestMetrics_Requests(t *testing.T) {
+	host, port := newFakeServer(t, stallAfterHandshake).addr()
+	metrics := NewMetrics()
+	cli := newClient(host, port, WithMetrics(metrics), WithTimeout(20*time.Millisecond),
+		WithRetries(1), WithRetryDelay(time.Millisecond))
+	defer cli.Close()
+	req, resp, _ := v1.NewGetSecurityCount(v1.MarketShangHai)
+	assert.Error(t, cli.Do(req, resp))
+
+	stats := metrics.Snapshot().Commands
+	var count CommandStats
+	for _, s := range stats {
+		if s.Command == "GetSecurityCount" {
+			count = s
+		}
+	}
+	assert.Equal(t, int64(1), count.Requests)
+	assert.Equal(t, int64(1), count.Latency.Count)
+	assert.Equal(t, map[string]int64{"timeout": 1}, count.Errors)
+	assert.Equal(t, int64(2), count.Attempts)
+	assert.Equal(t, map[string]int64{"timeout": 2}, count.TransportErrors)
+
+	// 缓存命中与限流均在拦截器中完成，同样计入统计
+	host, port = newFakeServer(t, echoHandler).addr()
+	metrics = NewMetrics()
+	cli = newClient(host, port, WithMetrics(metrics), WithCache(NewMemoryCache(10), DefaultCachePolicy),
+		WithRateLimit(RateLimits{Default: Limit{Rate: 0.001, Burst: 1}}))
+	defer cli.Close()
+	for i := 0; i < 2; i++ {
+		req, resp, _ := v1.NewGetSecurityCount(v1.MarketShangHai)
+		assert.NoError(t, cli.Do(req, resp))
+	}
+	listReq, listResp, _ := v1.NewGetSecurityList(v1.MarketShangHai, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.True(t, errors.Is(cli.DoContext(ctx, listReq, listResp), ErrRateLimited))
+
+	byCommand := map[string]CommandStats{}
+	for _, stats := range metrics.Snapshot().Commands {
+		byCommand[stats.Command] = stats
+	}
+	assert.Equal(t, int64(2), byCommand["GetSecurityCount"].Requests)
+	assert.Equal(t, int64(1), byCommand["GetSecurityCount"].CacheHits)
+	assert.Equal(t, int64(1), byCommand["GetSecurityCount"].Attempts)
+	assert.Equal(t, map[string]int64{"rate_limited": 1}, byCommand["GetSecurityList"].Errors)
+	assert.Equal(t, int64(0), byCommand["GetSecurityList"].Attempts)
+}
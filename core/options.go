@@ -0,0 +1,163 @@
+package core
+
+import (
+	"context"
+	"github.com/cyclegen-community/tdx-go/proxy"
+	"github.com/cyclegen-community/tdx-go/utils/logger"
+	"net"
+	"time"
+)
+
+// Option 客户端配置项
+type Option func(*Client)
+
+// DialContextFunc 自定义建立连接的方法
+type DialContextFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// WithTimeout 设置连接与单次请求的超时时间，0表示不限制
+func WithTimeout(timeout time.Duration) Option {
+	return func(cli *Client) {
+		cli.Timeout = timeout
+	}
+}
+
+// WithRetries 设置连接失败或连接中断时的最大重试次数
+func WithRetries(times int) Option {
+	return func(cli *Client) {
+		cli.MaxRetryTimes = times
+	}
+}
+
+// WithRetryDelay 设置首次重试前的等待时间，之后按指数退避
+func WithRetryDelay(delay time.Duration) Option {
+	return func(cli *Client) {
+		cli.RetryDuration = delay
+	}
+}
+
+// WithDialer 使用指定的net.Dialer建立连接，nil表示使用默认的net.Dialer
+func WithDialer(dialer *net.Dialer) Option {
+	return func(cli *Client) {
+		if dialer == nil {
+			cli.dialContext = nil
+			return
+		}
+		cli.dialContext = dialer.DialContext
+	}
+}
+
+// WithDialContext 使用自定义方法建立连接，可用于代理或测试替身
+func WithDialContext(dial DialContextFunc) Option {
+	return func(cli *Client) {
+		cli.dialContext = dial
+	}
+}
+
+// WithProxy 经由代理建立连接，代理可由proxy.Parse根据地址创建，nil表示直连
+func WithProxy(dialer proxy.Dialer) Option {
+	return func(cli *Client) {
+		if dialer == nil {
+			dialer = proxy.Direct
+		}
+		cli.dialContext = dialer.DialContext
+	}
+}
+
+// WithLogger 设置日志输出，默认不输出任何日志
+func WithLogger(l logger.Logger) Option {
+	return func(cli *Client) {
+		if l == nil {
+			l = logger.Nop
+		}
+		cli.logger = l
+	}
+}
+
+// WithDebug 调试模式下以Debug级别输出收发数据包的十六进制转储
+func WithDebug(debug bool) Option {
+	return func(cli *Client) {
+		cli.debug = debug
+	}
+}
+
+// WithHandshake 设置建立连接后是否自动完成握手，默认开启
+func WithHandshake(handshake bool) Option {
+	return func(cli *Client) {
+		cli.Handshake = handshake
+	}
+}
+
+// WithHeartbeat 连接建立后启动后台心跳，见Client.StartHeartbeat
+func WithHeartbeat(interval time.Duration) Option {
+	return func(cli *Client) {
+		cli.heartbeatInterval = interval
+	}
+}
+
+// WithStateChange 设置连接状态变化时的回调，在状态切换的协程中同步调用
+// 回调在首次连接前设置，可观察到Dial过程中的状态变化
+func WithStateChange(fn func(old, new ConnState)) Option {
+	return func(cli *Client) {
+		cli.onStateChange = fn
+	}
+}
+
+// WithInterceptors 追加请求拦截器，先添加的位于外层
+func WithInterceptors(interceptors ...Interceptor) Option {
+	return func(cli *Client) {
+		cli.interceptors = append(cli.interceptors, interceptors...)
+	}
+}
+
+// WithRateLimit 为当前连接单独限流
+func WithRateLimit(limits RateLimits) Option {
+	return func(cli *Client) {
+		cli.interceptors = append(cli.interceptors, RateLimitInterceptor(NewRateLimiter(limits)))
+	}
+}
+
+// WithServerRateLimit 与连接到同一服务器的其他客户端共享限流额度，见RegisterServerRateLimit
+// 该服务器已使用不同的配置时沿用已有配置，并在创建客户端时输出警告日志
+func WithServerRateLimit(limits RateLimits) Option {
+	return func(cli *Client) {
+		limiter, err := RegisterServerRateLimit(cli.Addr(), limits)
+		if err != nil {
+			cli.optionWarnings = append(cli.optionWarnings, err)
+		}
+		cli.interceptors = append(cli.interceptors, RateLimitInterceptor(limiter))
+	}
+}
+
+// WithCache 按policy缓存响应，多个客户端可共享同一store
+// 拦截器按添加顺序包裹，应在WithRateLimit之前添加，使命中缓存的请求不占用限流额度
+func WithCache(store CacheStore, policy CachePolicy) Option {
+	return func(cli *Client) {
+		cli.interceptors = append(cli.interceptors, CacheInterceptor(store, policy))
+	}
+}
+
+// WithScoreboard 将连接与请求的结果记录到记分板，断路期间连接与请求直接返回ErrCircuitOpen
+func WithScoreboard(sb *Scoreboard) Option {
+	return func(cli *Client) {
+		cli.scoreboard = sb
+		cli.interceptors = append(cli.interceptors, ScoreboardInterceptor(sb, cli.Addr()))
+	}
+}
+
+// WithMetrics 将统计数据记录到指定实例，传入nil关闭统计，默认使用DefaultMetrics
+func WithMetrics(metrics *Metrics) Option {
+	return func(cli *Client) {
+		cli.metrics = metrics
+	}
+}
+
+// WithMaxBodySize 设置响应包体长度上限，默认为协议允许的最大值，即不做限制
+// size不大于0时恢复默认值
+func WithMaxBodySize(size int) Option {
+	return func(cli *Client) {
+		if size <= 0 {
+			size = defaultMaxBodySize
+		}
+		cli.MaxBodySize = size
+	}
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"github.com/cyclegen-community/tdx-go/proto"
+	"github.com/cyclegen-community/tdx-go/proto/v1"
+	"sync"
+)
+
+// ErrSecurityNotFound 服务器未返回该证券的行情，通常是市场或代码有误
+var ErrSecurityNotFound = errors.New("未找到证券行情")
+
+// QuoteResult 单只证券的行情查询结果，Err非空时Quote为nil
+type QuoteResult struct {
+	Security v1.Security       `json:"security"`
+	Quote    *v1.SecurityQuote `json:"quote"`
+	Err      error             `json:"-"`
+}
+
+// GetSecurityQuotes 查询任意数量证券的实时行情
+// 输入按每包v1.MaxSecurityQuotes只拆分，经连接池并行发送，结果与输入一一对应且顺序相同
+// 设置Hub.Hedge后各批次以对冲方式发送
+// 某个批次失败时只有该批次中的证券带有错误；返回的error仅在连接池为空或ctx结束时非空
+func (hub *Hub) GetSecurityQuotes(ctx context.Context, securities []v1.Security) ([]QuoteResult, error) {
+	workers := hub.Len()
+	if workers == 0 {
+		return nil, ErrNoClients
+	}
+	results := make([]QuoteResult, len(securities))
+	for idx := range securities {
+		results[idx].Security = securities[idx]
+	}
+
+	batches := make(chan []QuoteResult)
+	var wg sync.WaitGroup
+	if n := (len(securities) + v1.MaxSecurityQuotes - 1) / v1.MaxSecurityQuotes; n < workers {
+		workers = n
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				hub.getQuotesBatch(ctx, batch)
+			}
+		}()
+	}
+	for start := 0; start < len(results); start += v1.MaxSecurityQuotes {
+		end := start + v1.MaxSecurityQuotes
+		if end > len(results) {
+			end = len(results)
+		}
+		batches <- results[start:end]
+	}
+	close(batches)
+	wg.Wait()
+	return results, ctx.Err()
+}
+
+// getQuotesBatch 查询一个批次并将结果填回batch
+func (hub *Hub) getQuotesBatch(ctx context.Context, batch []QuoteResult) {
+	fail := func(err error) {
+		for idx := range batch {
+			batch[idx].Err = err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		fail(err)
+		return
+	}
+	securities := make([]v1.Security, len(batch))
+	for idx := range batch {
+		securities[idx] = batch[idx].Security
+	}
+	// 启用对冲时每个请求使用各自的请求与响应结构
+	result, err := hub.DoHedged(ctx, func() (req proto.Marshaler, resp proto.Unmarshaler, err error) {
+		req, resp, err = v1.NewGetSecurityQuotes(securities)
+		return
+	})
+	if err != nil {
+		fail(err)
+		return
+	}
+	resp := result.(*v1.GetSecurityQuotesResponse)
+
+	// 服务器会略过不认识的证券，按代码而非位置对应
+	quotes := make(map[v1.Security]*v1.SecurityQuote, len(resp.Quotes))
+	for idx := range resp.Quotes {
+		quotes[resp.Quotes[idx].Security()] = &resp.Quotes[idx]
+	}
+	for idx := range batch {
+		if quote, ok := quotes[batch[idx].Security]; ok {
+			batch[idx].Quote = quote
+		} else {
+			batch[idx].Err = ErrSecurityNotFound
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/cyclegen-community/tdx-go/proto"
+	"github.com/cyclegen-community/tdx-go/proto/v1"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+// quotesHandler 按请求中的证券返回行情，价格取自代码数值
+// 以9开头的代码视为不存在，批次中包含999999时拒绝整个请求
+func quotesHandler(batches *int32) func(cmd uint16, body []byte) ([]byte, bool) {
+	return func(cmd uint16, body []byte) ([]byte, bool) {
+		if cmd != uint16(v1.CmdGetSecurityQuotes) {
+			return body, true
+		}
+		atomic.AddInt32(batches, 1)
+		count := int(binary.LittleEndian.Uint16(body[8:]))
+		w := proto.NewWriter(nil)
+		var found int
+		for idx := 0; idx < count; idx++ {
+			record := body[10+idx*7 : 17+idx*7]
+			code := string(record[1:])
+			if code == "999999" {
+				return nil, true
+			}
+			if code[0] == '9' {
+				continue
+			}
+			found++
+			price, _ := strconv.Atoi(code)
+			w.Uint8(record[0])
+			w.Raw(record[1:])
+			w.Uint16(0)
+			w.VarInt(price)
+			// 其余字段均为1字节的0
+			w.Raw(make([]byte, 56-10))
+		}
+		data := make([]byte, 4, 4+w.Len())
+		binary.LittleEndian.PutUint16(data[2:], uint16(found))
+		return append(data, w.Bytes()...), true
+	}
+}
+
+func TestHub_GetSecurityQuotes(t *testing.T) {
+	var batches int32
+	hub := NewHub()
+	for i := 0; i < 4; i++ {
+		hub.Add(NewClient(newFakeServer(t, quotesHandler(&batches)).addr()))
+	}
+	defer hub.Close()
+
+	const total = 5000
+	securities := make([]v1.Security, total)
+	for idx := range securities {
+		securities[idx] = v1.Security{Market: v1.Market(idx % 2), Code: fmt.Sprintf("%06d", idx)}
+	}
+	securities[100].Code = "900100"
+	// 第3个批次被整体拒绝
+	securities[170].Code = "999999"
+
+	results, err := hub.GetSecurityQuotes(context.Background(), securities)
+	assert.NoError(t, err)
+	assert.Equal(t, int32((total+v1.MaxSecurityQuotes-1)/v1.MaxSecurityQuotes), atomic.LoadInt32(&batches))
+	if !assert.Len(t, results, total) {
+		return
+	}
+	for idx, result := range results {
+		assert.Equal(t, securities[idx], result.Security)
+		switch {
+		case idx == 100:
+			assert.True(t, errors.Is(result.Err, ErrSecurityNotFound))
+		case idx >= 160 && idx < 240:
+			assert.True(t, errors.Is(result.Err, ErrServerRejected))
+		case assert.NoError(t, result.Err):
+			assert.Equal(t, float64(idx)/100, result.Quote.Price)
+		}
+	}
+}
+
+func TestHub_GetSecurityQuotesCanceled(t *testing.T) {
+	var batches int32
+	hub := NewHub(NewClient(newFakeServer(t, quotesHandler(&batches)).addr()))
+	defer hub.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err := hub.GetSecurityQuotes(ctx, make([]v1.Security, 200))
+	assert.Equal(t, context.Canceled, err)
+	for _, result := range results {
+		assert.Equal(t, context.Canceled, result.Err)
+	}
+	assert.Equal(t, int32(0), atomic.LoadInt32(&batches))
+
+	_, err = NewHub().GetSecurityQuotes(context.Background(), nil)
+	assert.True(t, errors.Is(err, ErrNoClients))
+}
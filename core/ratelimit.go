@@ -0,0 +1,203 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"github.com/cyclegen-community/tdx-go/proto"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CommandClass 指令类别，不同类别使用各自独立的限流额度
+type CommandClass string
+
+const (
+	ClassDefault CommandClass = "default"
+	ClassQuotes  CommandClass = "quotes"
+	ClassBars    CommandClass = "bars"
+	ClassFiles   CommandClass = "files"
+)
+
+// DefaultClassify 按指令名称归类: 含Quotes为行情，含Bars为K线，含File为文件，其余为默认类别
+func DefaultClassify(request proto.Marshaler) CommandClass {
+	name := proto.CommandName(request)
+	switch {
+	case strings.Contains(name, "Quotes"):
+		return ClassQuotes
+	case strings.Contains(name, "Bars"):
+		return ClassBars
+	case strings.Contains(name, "File"):
+		return ClassFiles
+	}
+	return ClassDefault
+}
+
+// Limit 令牌桶配置
+type Limit struct {
+	// Rate 每秒补充的令牌数，不大于0表示不限流
+	Rate float64
+	// Burst 桶容量，即允许的突发请求数，最小为1
+	Burst int
+}
+
+// RateLimits 限流配置
+type RateLimits struct {
+	// Default 未单独配置的类别使用的额度
+	Default Limit
+	// Classes 各指令类别的独立额度
+	Classes map[CommandClass]Limit
+	// Classify 指令归类方法，为空时使用DefaultClassify
+	Classify func(proto.Marshaler) CommandClass
+}
+
+// TokenBucket 令牌桶限流器
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket 创建装满令牌的令牌桶
+func NewTokenBucket(limit Limit) *TokenBucket {
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &TokenBucket{
+		rate:   limit.Rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// Wait 取得一个令牌
+// 没有可用令牌时阻塞等待；若ctx的截止时间早于令牌可用时间则不等待，立即返回ErrRateLimited
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	if b.rate <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		b.mu.Unlock()
+		return nil
+	}
+	wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(now.Add(wait)) {
+		b.mu.Unlock()
+		return ErrRateLimited
+	}
+	// 预占令牌，后续调用者在此之后排队
+	b.tokens--
+	b.mu.Unlock()
+	if err := sleepContext(ctx, wait); err != nil {
+		b.mu.Lock()
+		b.tokens++
+		b.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// RateLimiter 按指令类别分别限流
+type RateLimiter struct {
+	limits  RateLimits
+	mu      sync.Mutex
+	buckets map[CommandClass]*TokenBucket
+}
+
+// NewRateLimiter 创建限流器
+func NewRateLimiter(limits RateLimits) *RateLimiter {
+	if limits.Classify == nil {
+		limits.Classify = DefaultClassify
+	}
+	return &RateLimiter{
+		limits:  limits,
+		buckets: map[CommandClass]*TokenBucket{},
+	}
+}
+
+// Wait 为请求所属的类别取得一个令牌，阻塞与快速失败规则见TokenBucket.Wait
+func (l *RateLimiter) Wait(ctx context.Context, request proto.Marshaler) error {
+	return l.bucket(l.limits.Classify(request)).Wait(ctx)
+}
+
+func (l *RateLimiter) bucket(class CommandClass) *TokenBucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	bucket, ok := l.buckets[class]
+	if !ok {
+		limit, ok := l.limits.Classes[class]
+		if !ok {
+			limit = l.limits.Default
+		}
+		bucket = NewTokenBucket(limit)
+		l.buckets[class] = bucket
+	}
+	return bucket
+}
+
+// 同一服务器的所有连接共享的限流器
+var serverRateLimiters = struct {
+	sync.Mutex
+	limiters map[string]*RateLimiter
+}{limiters: map[string]*RateLimiter{}}
+
+// ServerRateLimiter 返回服务器addr在进程内共享的限流器
+// 首次调用时按limits创建，之后的调用沿用已有配置，需要发现配置冲突时使用RegisterServerRateLimit
+func ServerRateLimiter(addr string, limits RateLimits) *RateLimiter {
+	limiter, _ := RegisterServerRateLimit(addr, limits)
+	return limiter
+}
+
+// RegisterServerRateLimit 返回服务器addr在进程内共享的限流器，首次调用时按limits创建
+// addr已按不同的limits创建过限流器时仍返回已有的限流器，同时返回ErrRateLimitConflict
+func RegisterServerRateLimit(addr string, limits RateLimits) (*RateLimiter, error) {
+	serverRateLimiters.Lock()
+	defer serverRateLimiters.Unlock()
+	limiter, ok := serverRateLimiters.limiters[addr]
+	if !ok {
+		limiter = NewRateLimiter(limits)
+		serverRateLimiters.limiters[addr] = limiter
+		return limiter, nil
+	}
+	if !sameLimits(limiter.limits, limits) {
+		return limiter, fmt.Errorf("%w: %s", ErrRateLimitConflict, addr)
+	}
+	return limiter, nil
+}
+
+// sameLimits 比较两份限流配置，Classify为空等同于DefaultClassify
+func sameLimits(a, b RateLimits) bool {
+	classify := func(l RateLimits) uintptr {
+		if l.Classify == nil {
+			return reflect.ValueOf(DefaultClassify).Pointer()
+		}
+		return reflect.ValueOf(l.Classify).Pointer()
+	}
+	if len(a.Classes) == 0 && len(b.Classes) == 0 {
+		a.Classes, b.Classes = nil, nil
+	}
+	return a.Default == b.Default && reflect.DeepEqual(a.Classes, b.Classes) && classify(a) == classify(b)
+}
+
+// RateLimitInterceptor 请求发出前向限流器申请令牌，连接中断后的自动重试不重复计数
+func RateLimitInterceptor(limiter *RateLimiter) Interceptor {
+	return func(ctx context.Context, request proto.Marshaler, response proto.Unmarshaler, next Invoker) error {
+		if err := limiter.Wait(ctx, request); err != nil {
+			return err
+		}
+		return next(ctx, request, response)
+	}
+}
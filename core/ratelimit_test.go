@@ -0,0 +1,60 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"github.com/cyclegen-community/tdx-go/proto/v1"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket_Wait(t *testing.T) {
+	bucket := NewTokenBucket(Limit{Rate: 20, Burst: 2})
+	ctx := context.Background()
+	assert.NoError(t, bucket.Wait(ctx))
+	assert.NoError(t, bucket.Wait(ctx))
+
+	// 截止时间内等不到令牌时快速失败
+	short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.Equal(t, ErrRateLimited, bucket.Wait(short))
+	assert.True(t, time.Since(start) < 10*time.Millisecond)
+
+	// 未设置截止时间时阻塞等待
+	assert.NoError(t, bucket.Wait(ctx))
+	assert.True(t, time.Since(start) >= 40*time.Millisecond)
+}
+
+func TestRateLimiter_Wait(t *testing.T) {
+	limiter := NewRateLimiter(RateLimits{
+		Default: Limit{Rate: 1, Burst: 1},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	req, _, _ := v1.NewGetSecurityCount(v1.MarketShangHai)
+	assert.NoError(t, limiter.Wait(ctx, req))
+	assert.Equal(t, ErrRateLimited, limiter.Wait(ctx, req))
+	// 心跳与查询数量同属默认类别
+	heartbeat, _, _ := v1.NewHeartbeat()
+	assert.Equal(t, ErrRateLimited, limiter.Wait(ctx, heartbeat))
+	assert.Equal(t, ClassDefault, DefaultClassify(heartbeat))
+}
+
+func TestRegisterServerRateLimit(t *testing.T) {
+	const addr = "127.0.0.1:65001"
+	limits := RateLimits{Default: Limit{Rate: 10, Burst: 1}}
+	limiter, err := RegisterServerRateLimit(addr, limits)
+	assert.NoError(t, err)
+	same, err := RegisterServerRateLimit(addr, RateLimits{Default: Limit{Rate: 10, Burst: 1}, Classify: DefaultClassify})
+	assert.NoError(t, err)
+	assert.True(t, limiter == same)
+
+	// 配置不同时沿用已有的限流器并报告冲突
+	stricter, err := RegisterServerRateLimit(addr, RateLimits{Default: Limit{Rate: 1, Burst: 1}})
+	assert.True(t, errors.Is(err, ErrRateLimitConflict))
+	assert.True(t, limiter == stricter)
+	assert.True(t, limiter == ServerRateLimiter(addr, RateLimits{}))
+}
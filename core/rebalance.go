@@ -0,0 +1,331 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"github.com/cyclegen-community/tdx-go/utils/logger"
+	"time"
+)
+
+// HubEventType 连接池事件类型
+type HubEventType int
+
+const (
+	// EventProbed 完成一轮体检，Reports为按RankHealth排序的结果
+	EventProbed HubEventType = iota
+	// EventMigrating 发现更优的服务器，开始将From上的一个连接迁移到To
+	EventMigrating
+	// EventMigrated 新连接已加入连接池，旧连接不再接收新请求
+	EventMigrated
+	// EventMigrationFailed 无法连接到To，旧连接保持不变
+	EventMigrationFailed
+	// EventDrained 旧连接上的在途请求已完成并关闭，Err非空表示等待超时后强制关闭
+	EventDrained
+)
+
+func (t HubEventType) String() string {
+	switch t {
+	case EventProbed:
+		return "probed"
+	case EventMigrating:
+		return "migrating"
+	case EventMigrated:
+		return "migrated"
+	case EventMigrationFailed:
+		return "migration_failed"
+	case EventDrained:
+		return "drained"
+	}
+	return "unknown"
+}
+
+// HubEvent 后台重新排序过程中产生的事件
+type HubEvent struct {
+	Type    HubEventType
+	From    string
+	To      string
+	Reports []HealthReport
+	Err     error
+}
+
+func (e HubEvent) String() string {
+	switch e.Type {
+	case EventProbed:
+		healthy := 0
+		for _, r := range e.Reports {
+			if r.Healthy() {
+				healthy++
+			}
+		}
+		if healthy == 0 {
+			return fmt.Sprintf("体检%d台服务器，均不可用", len(e.Reports))
+		}
+		return fmt.Sprintf("体检%d台服务器，%d台可用，最优%s 延迟%v", len(e.Reports), healthy, e.Reports[0].Addr, e.Reports[0].Latency)
+	case EventMigrating:
+		return fmt.Sprintf("开始迁移连接 %s -> %s", e.From, e.To)
+	case EventMigrated:
+		return fmt.Sprintf("已迁移连接 %s -> %s", e.From, e.To)
+	case EventMigrationFailed:
+		return fmt.Sprintf("迁移连接 %s -> %s 失败: %v", e.From, e.To, e.Err)
+	case EventDrained:
+		if e.Err != nil {
+			return fmt.Sprintf("旧连接%s强制关闭: %v", e.From, e.Err)
+		}
+		return fmt.Sprintf("旧连接%s已关闭", e.From)
+	}
+	return e.Type.String()
+}
+
+// LogHubEvents 返回将事件写入日志的回调，可用作RebalancePolicy.OnEvent
+func LogHubEvents(l logger.Logger) func(HubEvent) {
+	return func(e HubEvent) {
+		if e.Err != nil {
+			l.Warnf("%v", e)
+		} else {
+			l.Infof("%v", e)
+		}
+	}
+}
+
+// RebalancePolicy 后台重新排序策略
+type RebalancePolicy struct {
+	// Addrs 候选服务器，连接池当前使用的服务器总会一并体检
+	Addrs []string
+	// Interval 体检间隔，默认5分钟
+	Interval time.Duration
+	// MinImprovement 候选服务器的延迟至少比当前服务器低出该比例才迁移，默认0.3，避免在相近的服务器间来回迁移
+	// 当前服务器体检失败或数据滞后时只要有健康的候选服务器即迁移
+	MinImprovement float64
+	// Spread 连接分散到的服务器数，默认2，不超过连接数与健康服务器数
+	// 连接不会全部集中到同一台服务器，单台服务器故障时仍有其他连接可用，对冲请求也有另一台服务器可选
+	Spread int
+	// DrainTimeout 等待旧连接上在途请求完成的最长时间，超时后强制关闭，默认30秒
+	DrainTimeout time.Duration
+	// Options 体检使用的配置，新连接沿用被替换连接创建时的配置
+	Options []Option
+	// OnEvent 事件回调，在后台协程中同步调用
+	OnEvent func(HubEvent)
+}
+
+func (p *RebalancePolicy) defaults() {
+	if p.Interval <= 0 {
+		p.Interval = 5 * time.Minute
+	}
+	if p.MinImprovement <= 0 {
+		p.MinImprovement = 0.3
+	}
+	if p.Spread <= 0 {
+		p.Spread = 2
+	}
+	if p.DrainTimeout <= 0 {
+		p.DrainTimeout = 30 * time.Second
+	}
+}
+
+func (p *RebalancePolicy) emit(e HubEvent) {
+	if p.OnEvent != nil {
+		p.OnEvent(e)
+	}
+}
+
+// StartRebalance 启动后台协程，每隔policy.Interval体检一次并将连接迁移到明显更优的服务器，见Rebalance
+func (hub *Hub) StartRebalance(policy RebalancePolicy) {
+	policy.defaults()
+	hub.rebalanceMu.Lock()
+	defer hub.rebalanceMu.Unlock()
+	if hub.rebalanceCancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	hub.rebalanceCancel, hub.rebalanceDone = cancel, done
+	go func() {
+		defer close(done)
+		timer := time.NewTimer(policy.Interval)
+		defer timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+			hub.Rebalance(ctx, policy)
+			timer.Reset(policy.Interval)
+		}
+	}()
+}
+
+// StopRebalance 停止后台重新排序并等待其退出，正在排空的旧连接会立即关闭
+func (hub *Hub) StopRebalance() {
+	hub.rebalanceMu.Lock()
+	cancel, done := hub.rebalanceCancel, hub.rebalanceDone
+	hub.rebalanceCancel, hub.rebalanceDone = nil, nil
+	hub.rebalanceMu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// Rebalance 体检候选服务器与连接池当前使用的服务器，将连接逐个迁移到延迟最低的Spread台健康服务器上，见plan
+// 迁移时先建立新连接并替换旧连接，旧连接不再接收新请求，等待其在途请求完成后再关闭，请求不会因迁移而失败
+func (hub *Hub) Rebalance(ctx context.Context, policy RebalancePolicy) {
+	policy.defaults()
+	addrs := append([]string(nil), policy.Addrs...)
+	seen := map[string]bool{}
+	for _, addr := range addrs {
+		seen[addr] = true
+	}
+	for _, cli := range hub.Clients() {
+		if !seen[cli.Addr()] {
+			seen[cli.Addr()] = true
+			addrs = append(addrs, cli.Addr())
+		}
+	}
+	reports := CheckHealth(ctx, addrs, policy.Options...)
+	if ctx.Err() != nil {
+		return
+	}
+	policy.emit(HubEvent{Type: EventProbed, Reports: reports})
+	for _, m := range policy.plan(hub.Clients(), reports) {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := hub.migrate(ctx, m.cli, m.addr, &policy); err != nil {
+			return
+		}
+	}
+}
+
+// migration 将cli迁移到addr
+type migration struct {
+	cli  *Client
+	addr string
+}
+
+// plan 以排好序的体检结果选出延迟最低的Spread台健康服务器作为目标，返回需要迁移的连接
+// 已关闭的连接最先替换，与体检失败或数据滞后的连接一样迁往连接最少的目标；
+// 其余连接仅在目标明显更优，或目标尚无连接而当前服务器上有多个连接时迁移
+func (p *RebalancePolicy) plan(clients []*Client, reports []HealthReport) []migration {
+	spread := p.Spread
+	if spread > len(clients) {
+		spread = len(clients)
+	}
+	var targets []HealthReport
+	for _, r := range reports {
+		if len(targets) == spread || !r.Healthy() {
+			break
+		}
+		targets = append(targets, r)
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+	byAddr := make(map[string]HealthReport, len(reports))
+	for _, r := range reports {
+		byAddr[r.Addr] = r
+	}
+	isTarget := make(map[string]bool, len(targets))
+	for _, t := range targets {
+		isTarget[t.Addr] = true
+	}
+	load := make(map[string]int, len(clients))
+	ordered := make([]*Client, 0, len(clients))
+	for _, cli := range clients {
+		if cli.State() == StateClosed {
+			ordered = append(ordered, cli)
+		}
+	}
+	for _, cli := range clients {
+		if cli.State() != StateClosed {
+			load[cli.Addr()]++
+			ordered = append(ordered, cli)
+		}
+	}
+	// lightest 连接最少的目标，连接数相同时取延迟低的
+	lightest := func() HealthReport {
+		best := targets[0]
+		for _, t := range targets[1:] {
+			if load[t.Addr] < load[best.Addr] {
+				best = t
+			}
+		}
+		return best
+	}
+
+	var plans []migration
+	for _, cli := range ordered {
+		target := lightest()
+		current, probed := byAddr[cli.Addr()]
+		closed := cli.State() == StateClosed
+		switch {
+		case closed, !probed, !current.Healthy():
+			// 必须迁移
+		case isTarget[cli.Addr()]:
+			if load[target.Addr] > 0 || load[cli.Addr()] <= 1 {
+				continue
+			}
+		case float64(target.Latency) > float64(current.Latency)*(1-p.MinImprovement):
+			continue
+		}
+		if !closed {
+			load[cli.Addr()]--
+		}
+		load[target.Addr]++
+		plans = append(plans, migration{cli: cli, addr: target.Addr})
+	}
+	return plans
+}
+
+// migrate 用连接到addr的新客户端替换old，并等待old排空后关闭，新客户端沿用old创建时的配置
+func (hub *Hub) migrate(ctx context.Context, old *Client, addr string, policy *RebalancePolicy) error {
+	policy.emit(HubEvent{Type: EventMigrating, From: old.Addr(), To: addr})
+	cli, err := Dial(ctx, addr, old.opts...)
+	if err != nil {
+		policy.emit(HubEvent{Type: EventMigrationFailed, From: old.Addr(), To: addr, Err: err})
+		return err
+	}
+	if !hub.replace(old, cli) {
+		// 旧连接已被移出连接池
+		cli.Close()
+		return nil
+	}
+	policy.emit(HubEvent{Type: EventMigrated, From: old.Addr(), To: addr})
+	err = drain(ctx, old, policy.DrainTimeout)
+	old.Close()
+	policy.emit(HubEvent{Type: EventDrained, From: old.Addr(), Err: err})
+	return nil
+}
+
+// replace 在连接池中用cli替换old，old不在池中时返回false
+// 替换在写锁内完成，之后pick不会再选中old，old上的在途请求数只减不增
+func (hub *Hub) replace(old, cli *Client) bool {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	for idx := range hub.clients {
+		if hub.clients[idx] == old {
+			hub.clients[idx] = cli
+			return true
+		}
+	}
+	return false
+}
+
+// drain 等待经连接池发往cli的请求全部完成，超时返回ErrTimeout，ctx结束时返回ctx的错误
+func drain(ctx context.Context, cli *Client, timeout time.Duration) error {
+	done := make(chan struct{})
+	go func() {
+		cli.inflight.Wait()
+		close(done)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return nil
+	case <-timer.C:
+		return ErrTimeout
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,200 @@
+package core
+
+import (
+	"context"
+	"github.com/cyclegen-community/tdx-go/proto/v1"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// slowHandler 体检请求延迟delay后响应，深圳市场的证券数量请求阻塞到release关闭
+func slowHandler(delay time.Duration, received, release chan struct{}) func(cmd uint16, body []byte) ([]byte, bool) {
+	healthy := healthHandler(1500, time.Now())
+	return func(cmd uint16, body []byte) ([]byte, bool) {
+		if cmd == 0x044e && body[0] == byte(v1.MarketShenZhen) {
+			close(received)
+			<-release
+		} else if cmd == 0x044e {
+			time.Sleep(delay)
+		}
+		return healthy(cmd, body)
+	}
+}
+
+func collectEvents(events chan HubEvent) func(HubEvent) {
+	return func(e HubEvent) {
+		events <- e
+	}
+}
+
+// nextEvent 跳过其他事件，等待类型为typ的事件
+func nextEvent(t *testing.T, events chan HubEvent, typ HubEventType) HubEvent {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e := <-events:
+			if e.Type == typ {
+				return e
+			}
+		case <-timeout:
+			t.Fatalf("未收到%v事件", typ)
+		}
+	}
+}
+
+func TestHub_Rebalance(t *testing.T) {
+	received, release := make(chan struct{}), make(chan struct{})
+	slow := fakeServerAddr(newFakeServer(t, slowHandler(50*time.Millisecond, received, release)))
+	fast := fakeServerAddr(newFakeServer(t, healthHandler(1500, time.Now())))
+	hub, err := DialHub(context.Background(), []string{slow}, WithTimeout(3*time.Second))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer hub.Close()
+	old := hub.Clients()[0]
+
+	// 迁移开始前已发出的请求
+	result := make(chan error, 1)
+	go func() {
+		req, resp, _ := v1.NewGetSecurityCount(v1.MarketShenZhen)
+		result <- hub.Do(req, resp)
+	}()
+	<-received
+
+	events := make(chan HubEvent, 16)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		hub.Rebalance(context.Background(), RebalancePolicy{Addrs: []string{fast}, OnEvent: collectEvents(events)})
+	}()
+	probed := nextEvent(t, events, EventProbed)
+	if assert.Len(t, probed.Reports, 2) {
+		assert.Equal(t, fast, probed.Reports[0].Addr)
+	}
+	migrated := nextEvent(t, events, EventMigrated)
+	assert.Equal(t, slow, migrated.From)
+	assert.Equal(t, fast, migrated.To)
+	assert.Equal(t, fast, hub.Clients()[0].Addr())
+	// 新连接沿用旧连接的配置
+	assert.Equal(t, 3*time.Second, hub.Clients()[0].Timeout)
+
+	// 新请求发往新服务器，旧连接等待在途请求完成
+	req, resp, _ := v1.NewGetSecurityCount(v1.MarketShangHai)
+	assert.NoError(t, hub.Do(req, resp))
+	assert.NotEqual(t, StateClosed, old.State())
+	select {
+	case err := <-result:
+		t.Fatalf("在途请求提前结束: %v", err)
+	default:
+	}
+
+	close(release)
+	assert.NoError(t, <-result)
+	drained := nextEvent(t, events, EventDrained)
+	assert.NoError(t, drained.Err)
+	<-done
+	assert.Equal(t, StateClosed, old.State())
+}
+
+func TestHub_RebalanceKeep(t *testing.T) {
+	a := fakeServerAddr(newFakeServer(t, healthHandler(1500, time.Now())))
+	b := fakeServerAddr(newFakeServer(t, healthHandler(1500, time.Now())))
+	hub, err := DialHub(context.Background(), []string{a})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer hub.Close()
+
+	// 两台服务器延迟相近，不迁移
+	var events []HubEvent
+	hub.Rebalance(context.Background(), RebalancePolicy{
+		Addrs:          []string{b},
+		MinImprovement: 0.9,
+		OnEvent:        func(e HubEvent) { events = append(events, e) },
+	})
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, EventProbed, events[0].Type)
+	}
+	assert.Equal(t, a, hub.Clients()[0].Addr())
+}
+
+func TestRebalancePolicy_Plan(t *testing.T) {
+	clientsOn := func(addrs ...string) []*Client {
+		clients := make([]*Client, len(addrs))
+		for idx, addr := range addrs {
+			clients[idx] = newClient(addr, 7709)
+		}
+		return clients
+	}
+	today := time.Now()
+	reports := RankHealth([]HealthReport{
+		{Addr: "a:7709", Latency: 10 * time.Millisecond, LatestBar: today},
+		{Addr: "b:7709", Latency: 12 * time.Millisecond, LatestBar: today},
+		{Addr: "c:7709", Latency: 100 * time.Millisecond, LatestBar: today},
+		{Addr: "d:7709", Err: ErrTimeout},
+	})
+	policy := RebalancePolicy{}
+	policy.defaults()
+	targets := func(plans []migration) []string {
+		var addrs []string
+		for _, m := range plans {
+			addrs = append(addrs, m.addr)
+		}
+		return addrs
+	}
+
+	// 连接分散到两台最优的服务器，而不是全部迁往最优的一台
+	plans := policy.plan(clientsOn("c", "c", "c", "c"), reports)
+	assert.Equal(t, []string{"a:7709", "b:7709", "a:7709", "b:7709"}, targets(plans))
+
+	// 全部集中在最优服务器上时分出一个连接
+	plans = policy.plan(clientsOn("a", "a", "a"), reports)
+	assert.Equal(t, []string{"b:7709"}, targets(plans))
+
+	// 已分散在两台服务器上，不迁移
+	assert.Empty(t, policy.plan(clientsOn("a", "b", "a"), reports))
+
+	// 已关闭的连接最先替换，体检失败的连接必须迁移
+	clients := clientsOn("a", "d", "a")
+	clients[2].Close()
+	plans = policy.plan(clients, reports)
+	if assert.Len(t, plans, 2) {
+		assert.Equal(t, clients[2], plans[0].cli)
+		assert.Equal(t, "b:7709", plans[0].addr)
+		assert.Equal(t, clients[1], plans[1].cli)
+		assert.Equal(t, "a:7709", plans[1].addr)
+	}
+
+	// 只有一台健康的服务器时全部迁往该服务器
+	policy.Spread = 1
+	assert.Equal(t, []string{"a:7709", "a:7709"}, targets(policy.plan(clientsOn("c", "d"), reports)))
+}
+
+func TestHub_StartRebalance(t *testing.T) {
+	// 当前服务器数据滞后，只要有健康的服务器即迁移
+	stale := fakeServerAddr(newFakeServer(t, healthHandler(1500, time.Now().AddDate(0, 0, -1))))
+	fresh := fakeServerAddr(newFakeServer(t, healthHandler(1500, time.Now())))
+	hub, err := DialHub(context.Background(), []string{stale, stale})
+	if !assert.NoError(t, err) {
+		return
+	}
+	events := make(chan HubEvent, 64)
+	hub.StartRebalance(RebalancePolicy{
+		Addrs:    []string{fresh},
+		Interval: 10 * time.Millisecond,
+		OnEvent:  collectEvents(events),
+	})
+	nextEvent(t, events, EventDrained)
+	nextEvent(t, events, EventDrained)
+	for _, cli := range hub.Clients() {
+		assert.Equal(t, fresh, cli.Addr())
+	}
+	assert.NoError(t, hub.Close())
+	// Close停止后台协程后不再产生事件
+	for len(events) > 0 {
+		<-events
+	}
+	time.Sleep(30 * time.Millisecond)
+	assert.Empty(t, events)
+}
@@ -0,0 +1,407 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/cyclegen-community/tdx-go/proto"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 服务器的断路器处于断开状态，请求未发出
+var ErrCircuitOpen = errors.New("服务器已断路")
+
+// BreakerState 断路器状态
+type BreakerState int
+
+const (
+	// BreakerClosed 正常放行请求
+	BreakerClosed BreakerState = iota
+	// BreakerOpen 连续失败过多，拒绝全部请求直到OpenTimeout之后
+	BreakerOpen
+	// BreakerHalfOpen 断开超过OpenTimeout，放行一个试探请求，成功则恢复，失败则再次断开
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// MarshalText 以名称保存状态，使记分板文件便于阅读
+func (s BreakerState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *BreakerState) UnmarshalText(text []byte) error {
+	for _, state := range []BreakerState{BreakerClosed, BreakerOpen, BreakerHalfOpen} {
+		if state.String() == string(text) {
+			*s = state
+			return nil
+		}
+	}
+	return fmt.Errorf("未知的断路器状态: %q", text)
+}
+
+// ServerScore 单台服务器的运行记录
+type ServerScore struct {
+	Addr string `json:"addr"`
+	// Latency 请求耗时的指数加权滑动平均
+	Latency time.Duration `json:"latency"`
+	// ErrorRate 失败率的指数加权滑动平均，取值0到1
+	ErrorRate   float64   `json:"error_rate"`
+	Requests    int       `json:"requests"`
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"last_failure,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+	// Stale 最近一次体检发现行情数据滞后，见ProbeHealth
+	Stale     bool         `json:"stale"`
+	LatestBar time.Time    `json:"latest_bar,omitempty"`
+	State     BreakerState `json:"state"`
+	// ConsecutiveFailures 连续失败次数，成功后清零
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	OpenedAt            time.Time `json:"opened_at,omitempty"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// serverScore 记分板内部记录，试探请求是否在途不需要保存
+type serverScore struct {
+	ServerScore
+	trial bool
+}
+
+const (
+	// 滑动平均中新样本的权重
+	scoreAlpha = 0.2
+	// 失败率对排序的影响，失败率10%相当于延迟翻倍
+	errorPenalty = 10
+)
+
+// Scoreboard 记录各服务器的延迟、失败率与数据滞后情况，并为每台服务器维护断路器
+// 可通过WithScoreboard在请求时记录，通过Save与LoadScoreboard在进程重启之间保留，多个客户端可共享同一实例
+type Scoreboard struct {
+	mu      sync.Mutex
+	servers map[string]*serverScore
+
+	// FailureThreshold 连续失败多少次后断路，默认5次
+	FailureThreshold int
+	// OpenTimeout 断路后经过多久进入半开状态，默认30秒
+	OpenTimeout time.Duration
+	// MaxAge 超过该时间未更新的记录在选择服务器时视为未知，默认24小时
+	MaxAge time.Duration
+
+	now func() time.Time
+}
+
+// NewScoreboard 创建空的记分板
+func NewScoreboard() *Scoreboard {
+	return &Scoreboard{
+		servers:          map[string]*serverScore{},
+		FailureThreshold: 5,
+		OpenTimeout:      30 * time.Second,
+		MaxAge:           24 * time.Hour,
+		now:              time.Now,
+	}
+}
+
+// scoreboardFile 记分板文件格式
+type scoreboardFile struct {
+	Servers []ServerScore `json:"servers"`
+}
+
+// LoadScoreboard 从文件读取记分板，文件不存在时返回空的记分板
+func LoadScoreboard(path string) (*Scoreboard, error) {
+	sb := NewScoreboard()
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return sb, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file scoreboardFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, score := range file.Servers {
+		sb.servers[score.Addr] = &serverScore{ServerScore: score}
+	}
+	return sb, nil
+}
+
+// Save 将记分板写入文件，先写入临时文件再改名，写入中途退出不会损坏原有文件
+func (sb *Scoreboard) Save(path string) error {
+	data, err := json.MarshalIndent(scoreboardFile{Servers: sb.Scores()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, filepath.Base(path)+".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// get 返回addr的记录，不存在时创建，调用方需持有锁
+func (sb *Scoreboard) get(addr string) *serverScore {
+	score, ok := sb.servers[addr]
+	if !ok {
+		score = &serverScore{ServerScore: ServerScore{Addr: addr}}
+		sb.servers[addr] = score
+	}
+	return score
+}
+
+// state 返回当前的断路器状态，断开超过OpenTimeout时转为半开，调用方需持有锁
+func (sb *Scoreboard) state(score *serverScore) BreakerState {
+	if score.State == BreakerOpen && sb.now().Sub(score.OpenedAt) >= sb.OpenTimeout {
+		score.State = BreakerHalfOpen
+		score.trial = false
+	}
+	return score.State
+}
+
+// Allow 判断是否可以向addr发送请求，半开状态下同一时刻只放行一个试探请求
+// 返回true后须以Record或Release结束该请求
+func (sb *Scoreboard) Allow(addr string) bool {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	score := sb.get(addr)
+	switch sb.state(score) {
+	case BreakerOpen:
+		return false
+	case BreakerHalfOpen:
+		if score.trial {
+			return false
+		}
+		score.trial = true
+	}
+	return true
+}
+
+// Release 放弃经Allow放行但未产生结果的请求，如调用方取消了ctx
+func (sb *Scoreboard) Release(addr string) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	if score, ok := sb.servers[addr]; ok {
+		score.trial = false
+	}
+}
+
+// Record 记录一次请求的耗时与结果，并据此切换断路器状态
+func (sb *Scoreboard) Record(addr string, latency time.Duration, err error) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	sb.record(sb.get(addr), latency, err)
+}
+
+func (sb *Scoreboard) record(score *serverScore, latency time.Duration, err error) {
+	now := sb.now()
+	failed := 0.0
+	if err != nil {
+		failed = 1
+	}
+	if score.Requests == 0 {
+		score.ErrorRate = failed
+	} else {
+		score.ErrorRate += scoreAlpha * (failed - score.ErrorRate)
+	}
+	score.Requests++
+	score.UpdatedAt = now
+	score.trial = false
+	state := sb.state(score)
+	if err != nil {
+		score.Failures++
+		score.ConsecutiveFailures++
+		score.LastFailure = now
+		score.LastError = err.Error()
+		if state == BreakerHalfOpen || (state == BreakerClosed && score.ConsecutiveFailures >= sb.FailureThreshold) {
+			score.State = BreakerOpen
+			score.OpenedAt = now
+		}
+		return
+	}
+	if score.Latency == 0 {
+		score.Latency = latency
+	} else {
+		score.Latency += time.Duration(scoreAlpha * float64(latency-score.Latency))
+	}
+	score.ConsecutiveFailures = 0
+	if state == BreakerHalfOpen {
+		score.State = BreakerClosed
+	}
+}
+
+// RecordHealth 记录一次体检结果，体检耗时计入延迟，数据滞后的服务器标记为Stale
+func (sb *Scoreboard) RecordHealth(report HealthReport) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	score := sb.get(report.Addr)
+	sb.record(score, report.Latency, report.Err)
+	if report.Err == nil {
+		score.Stale = report.Stale
+		score.LatestBar = report.LatestBar
+	}
+}
+
+// Score 返回addr的记录
+func (sb *Scoreboard) Score(addr string) (ServerScore, bool) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	score, ok := sb.servers[addr]
+	if !ok {
+		return ServerScore{}, false
+	}
+	sb.state(score)
+	return score.ServerScore, true
+}
+
+// Scores 返回按地址排序的全部记录，尚无请求结果的服务器不包含在内
+func (sb *Scoreboard) Scores() []ServerScore {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	scores := make([]ServerScore, 0, len(sb.servers))
+	for _, score := range sb.servers {
+		if score.Requests == 0 {
+			continue
+		}
+		sb.state(score)
+		scores = append(scores, score.ServerScore)
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		return scores[i].Addr < scores[j].Addr
+	})
+	return scores
+}
+
+// 排序时的分组，数值越小越靠前
+const (
+	rankGood = iota
+	rankUnknown
+	rankDegraded
+	rankOpen
+)
+
+// rank 返回addr所属的分组与用于组内排序的有效延迟，调用方需持有锁
+func (sb *Scoreboard) rank(addr string) (int, time.Duration) {
+	score, ok := sb.servers[addr]
+	if !ok || score.Requests == 0 || sb.now().Sub(score.UpdatedAt) > sb.MaxAge {
+		return rankUnknown, 0
+	}
+	latency := time.Duration(float64(score.Latency) * (1 + errorPenalty*score.ErrorRate))
+	switch sb.state(score) {
+	case BreakerOpen:
+		return rankOpen, latency
+	case BreakerHalfOpen:
+		return rankDegraded, latency
+	}
+	// 最近一次请求失败的服务器在成功之前不视为良好
+	if score.Stale || score.ConsecutiveFailures > 0 {
+		return rankDegraded, latency
+	}
+	return rankGood, latency
+}
+
+// Rank 按记录对addrs排序: 状态良好的服务器按计入失败率的延迟从低到高排在最前，
+// 其后依次为没有近期记录的、数据滞后、最近失败或处于半开状态的、已断路的服务器，同组中按延迟排列
+func (sb *Scoreboard) Rank(addrs []string) []string {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	type ranked struct {
+		addr    string
+		group   int
+		latency time.Duration
+	}
+	items := make([]ranked, len(addrs))
+	for idx, addr := range addrs {
+		group, latency := sb.rank(addr)
+		items[idx] = ranked{addr: addr, group: group, latency: latency}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].group != items[j].group {
+			return items[i].group < items[j].group
+		}
+		return items[i].latency < items[j].latency
+	})
+	result := make([]string, len(items))
+	for idx := range items {
+		result[idx] = items[idx].addr
+	}
+	return result
+}
+
+// Best 返回addrs中状态良好且延迟最低的服务器，没有近期良好记录时返回false，此时应重新测速
+func (sb *Scoreboard) Best(addrs []string) (string, bool) {
+	ranked := sb.Rank(addrs)
+	if len(ranked) == 0 {
+		return "", false
+	}
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	if group, _ := sb.rank(ranked[0]); group != rankGood {
+		return "", false
+	}
+	return ranked[0], true
+}
+
+// countsAsFailure 判断错误是否说明服务器有问题，调用方取消、限流与参数错误等不计入
+func countsAsFailure(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	return IsTransient(err) || errors.Is(err, ErrTimeout)
+}
+
+// guard 在断路器允许时执行fn并记录结果，断路时返回ErrCircuitOpen
+func (sb *Scoreboard) guard(ctx context.Context, addr string, fn func() error) error {
+	if !sb.Allow(addr) {
+		return &OpError{Op: "dial", Addr: addr, Err: ErrCircuitOpen}
+	}
+	start := time.Now()
+	err := fn()
+	switch {
+	case err == nil:
+		sb.Record(addr, time.Since(start), nil)
+	case countsAsFailure(ctx, err):
+		sb.Record(addr, 0, err)
+	default:
+		sb.Release(addr)
+	}
+	return err
+}
+
+// ScoreboardInterceptor 在断路时拒绝发往addr的请求，并将请求结果记录到记分板
+func ScoreboardInterceptor(sb *Scoreboard, addr string) Interceptor {
+	return func(ctx context.Context, request proto.Marshaler, response proto.Unmarshaler, next Invoker) error {
+		return sb.guard(ctx, addr, func() error {
+			return next(ctx, request, response)
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"github.com/cyclegen-community/tdx-go/proto/v1"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestScoreboard 使用可手动推进的时钟
+func newTestScoreboard() (*Scoreboard, *time.Time) {
+	now := time.Date(2020, 10, 16, 9, 30, 0, 0, time.UTC)
+	sb := NewScoreboard()
+	sb.FailureThreshold = 3
+	sb.now = func() time.Time { return now }
+	return sb, &now
+}
+
+func TestScoreboard_Breaker(t *testing.T) {
+	sb, now := newTestScoreboard()
+	const addr = "127.0.0.1:7709"
+	for i := 0; i < 3; i++ {
+		assert.True(t, sb.Allow(addr))
+		sb.Record(addr, 0, ErrTimeout)
+	}
+	score, _ := sb.Score(addr)
+	assert.Equal(t, BreakerOpen, score.State)
+	assert.Equal(t, 3, score.ConsecutiveFailures)
+	assert.Equal(t, ErrTimeout.Error(), score.LastError)
+	assert.False(t, sb.Allow(addr))
+
+	// 超过OpenTimeout后只放行一个试探请求，试探失败时再次断开
+	*now = now.Add(sb.OpenTimeout)
+	assert.True(t, sb.Allow(addr))
+	assert.False(t, sb.Allow(addr))
+	sb.Record(addr, 0, ErrTimeout)
+	assert.False(t, sb.Allow(addr))
+
+	// 放弃的试探请求不占用名额
+	*now = now.Add(sb.OpenTimeout)
+	assert.True(t, sb.Allow(addr))
+	sb.Release(addr)
+	assert.True(t, sb.Allow(addr))
+	sb.Record(addr, 10*time.Millisecond, nil)
+	score, _ = sb.Score(addr)
+	assert.Equal(t, BreakerClosed, score.State)
+	assert.Equal(t, 0, score.ConsecutiveFailures)
+	assert.Equal(t, 10*time.Millisecond, score.Latency)
+	assert.True(t, sb.Allow(addr))
+	assert.True(t, sb.Allow(addr))
+}
+
+func TestScoreboard_Rank(t *testing.T) {
+	sb, now := newTestScoreboard()
+	sb.Record("slow", 30*time.Millisecond, nil)
+	sb.Record("fast", 10*time.Millisecond, nil)
+	// 延迟低于slow，计入失败率后排在其后
+	sb.Record("flaky", 12*time.Millisecond, nil)
+	sb.Record("flaky", 0, ErrTimeout)
+	sb.Record("flaky", 12*time.Millisecond, nil)
+	sb.RecordHealth(HealthReport{Addr: "stale", Latency: time.Millisecond, Stale: true})
+	for i := 0; i < 3; i++ {
+		sb.Record("open", time.Millisecond, ErrTimeout)
+	}
+
+	addrs := []string{"open", "unknown", "stale", "slow", "flaky", "fast"}
+	assert.Equal(t, []string{"fast", "slow", "flaky", "unknown", "stale", "open"}, sb.Rank(addrs))
+	best, ok := sb.Best(addrs)
+	assert.True(t, ok)
+	assert.Equal(t, "fast", best)
+
+	_, ok = sb.Best([]string{"unknown", "stale", "open"})
+	assert.False(t, ok)
+
+	// 过期的记录视为未知，需要重新测速
+	*now = now.Add(sb.MaxAge + time.Second)
+	_, ok = sb.Best(addrs)
+	assert.False(t, ok)
+}
+
+func TestScoreboard_SaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tdx-scoreboard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "scores", "scoreboard.json")
+	sb, err := LoadScoreboard(path)
+	assert.NoError(t, err)
+	assert.Empty(t, sb.Scores())
+
+	sb.Record("a", 10*time.Millisecond, nil)
+	sb.RecordHealth(HealthReport{Addr: "b", Latency: time.Millisecond, Stale: true})
+	for i := 0; i < sb.FailureThreshold; i++ {
+		sb.Record("c", 0, ErrTimeout)
+	}
+	// 未产生结果的服务器不保存
+	sb.Allow("d")
+	assert.NoError(t, sb.Save(path))
+
+	loaded, err := LoadScoreboard(path)
+	assert.NoError(t, err)
+	scores := loaded.Scores()
+	if !assert.Len(t, scores, 3) {
+		return
+	}
+	assert.Equal(t, 10*time.Millisecond, scores[0].Latency)
+	assert.True(t, scores[1].Stale)
+	assert.Equal(t, BreakerOpen, scores[2].State)
+	assert.False(t, loaded.Allow("c"))
+}
+
+func TestWithScoreboard(t *testing.T) {
+	sb := NewScoreboard()
+	sb.FailureThreshold = 2
+	addr := fakeServerAddr(newFakeServer(t, stallAfterHandshake))
+	cli, err := Dial(context.Background(), addr, WithScoreboard(sb), WithTimeout(50*time.Millisecond), WithRetries(0))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer cli.Close()
+	score, _ := sb.Score(addr)
+	assert.Equal(t, 1, score.Requests)
+
+	for i := 0; i < 2; i++ {
+		req, resp, _ := v1.NewGetSecurityCount(v1.MarketShangHai)
+		assert.True(t, errors.Is(cli.Do(req, resp), ErrTimeout))
+	}
+	req, resp, _ := v1.NewGetSecurityCount(v1.MarketShangHai)
+	assert.True(t, errors.Is(cli.Do(req, resp), ErrCircuitOpen))
+
+	// 调用方取消的请求不计为失败
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	sb.Record("127.0.0.1:1", time.Millisecond, nil)
+	cli2 := newClient("127.0.0.1", 1, WithScoreboard(sb))
+	assert.Error(t, cli2.DoContext(ctx, req, resp))
+	score, _ = sb.Score("127.0.0.1:1")
+	assert.Equal(t, 0, score.Failures)
+
+	// 连接失败同样计入，断路后Dial直接返回
+	for i := 0; i < 2; i++ {
+		_, err = Dial(context.Background(), "127.0.0.1:1", WithScoreboard(sb), WithRetries(0))
+		assert.True(t, errors.Is(err, ErrDial))
+	}
+	_, err = Dial(context.Background(), "127.0.0.1:1", WithScoreboard(sb), WithRetries(0))
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+}
+
+func TestWithScoreboard_DialRetries(t *testing.T) {
+	sb := NewScoreboard()
+	sb.FailureThreshold = 2
+	// 重试过程整体只计一次失败，不会因单次Dial的重试而断路
+	_, err := Dial(context.Background(), "127.0.0.1:1", WithScoreboard(sb), WithRetries(3), WithRetryDelay(time.Millisecond))
+	assert.True(t, errors.Is(err, ErrDial))
+	score, _ := sb.Score("127.0.0.1:1")
+	assert.Equal(t, 1, score.Requests)
+	assert.Equal(t, 1, score.Failures)
+	assert.Equal(t, BreakerClosed, score.State)
+}
@@ -0,0 +1,116 @@
+package core
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"testing"
+)
+
+// 测试用的行情服务器替身
+// handler返回响应体，返回false时不作响应以模拟服务器卡死
+type fakeServer struct {
+	ln      net.Listener
+	handler func(cmd uint16, body []byte) ([]byte, bool)
+	// corrupt 可在发送前改写完整的响应数据
+	corrupt func(resp []byte)
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func newFakeServer(t *testing.T, handler func(cmd uint16, body []byte) ([]byte, bool)) *fakeServer {
+	return startFakeServer(t, &fakeServer{handler: handler})
+}
+
+func startFakeServer(t *testing.T, srv *fakeServer) *fakeServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.ln = ln
+	t.Cleanup(srv.Close)
+	go srv.serve()
+	return srv
+}
+
+// echoHandler 原样返回请求体
+func echoHandler(cmd uint16, body []byte) ([]byte, bool) {
+	return body, true
+}
+
+// stallAfterHandshake 完成握手后对任何请求都不作响应
+func stallAfterHandshake(cmd uint16, body []byte) ([]byte, bool) {
+	if cmd == 0x000d || cmd == 0x0fdb {
+		return body, true
+	}
+	return nil, false
+}
+
+func (srv *fakeServer) addr() (host string, port int) {
+	addr := srv.ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+func (srv *fakeServer) serve() {
+	for {
+		conn, err := srv.ln.Accept()
+		if err != nil {
+			return
+		}
+		srv.mu.Lock()
+		srv.conns = append(srv.conns, conn)
+		srv.mu.Unlock()
+		go srv.handle(conn)
+	}
+}
+
+// dropConns 断开所有已建立的连接，模拟网络中断
+func (srv *fakeServer) dropConns() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	for _, conn := range srv.conns {
+		conn.Close()
+	}
+	srv.conns = nil
+}
+
+func (srv *fakeServer) Close() {
+	srv.ln.Close()
+	srv.dropConns()
+}
+
+func (srv *fakeServer) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		// 请求包头: flag(1) seq(4) type(1) len(2) len(2)，其后为len字节的cmd与包体
+		head := make([]byte, 10)
+		if _, err := io.ReadFull(conn, head); err != nil {
+			return
+		}
+		payload := make([]byte, binary.LittleEndian.Uint16(head[6:]))
+		if _, err := io.ReadFull(conn, payload); err != nil {
+			return
+		}
+		cmd := binary.LittleEndian.Uint16(payload)
+		body, ok := srv.handler(cmd, payload[2:])
+		if !ok {
+			continue
+		}
+		// 响应包头: magic(4) flag(1) seq(4) type(1) cmd(2) zip(2) unzip(2)
+		resp := make([]byte, 16, 16+len(body))
+		binary.LittleEndian.PutUint32(resp, 0x0074cbb1)
+		copy(resp[4:10], head[:6])
+		binary.LittleEndian.PutUint16(resp[10:], cmd)
+		binary.LittleEndian.PutUint16(resp[12:], uint16(len(body)))
+		binary.LittleEndian.PutUint16(resp[14:], uint16(len(body)))
+		resp = append(resp, body...)
+		if srv.corrupt != nil {
+			srv.corrupt(resp)
+		}
+		if _, err := conn.Write(resp); err != nil {
+			return
+		}
+	}
+}
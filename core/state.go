@@ -0,0 +1,52 @@
+package core
+
+// ConnState 客户端连接状态
+type ConnState int
+
+const (
+	// StateDisconnected 未连接或连接已断开
+	StateDisconnected ConnState = iota
+	// StateConnecting 正在建立连接并握手
+	StateConnecting
+	// StateConnected 连接可用
+	StateConnected
+	// StateClosed 客户端已关闭，不再重连
+	StateClosed
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case StateDisconnected:
+		return "disconnected"
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateClosed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+// State 返回当前连接状态
+func (cli *Client) State() ConnState {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	return cli.state
+}
+
+// setState 切换连接状态并触发WithStateChange设置的回调
+func (cli *Client) setState(state ConnState) {
+	cli.mu.Lock()
+	old := cli.state
+	// 已关闭的客户端不再离开关闭状态
+	if old == StateClosed || old == state {
+		cli.mu.Unlock()
+		return
+	}
+	cli.state = state
+	cli.mu.Unlock()
+	if cli.onStateChange != nil {
+		cli.onStateChange(old, state)
+	}
+}
@@ -0,0 +1,186 @@
+package proto
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// 通达信协议的整数均为小端序，报文布局固定，因此直接按字段顺序读写，不依赖反射
+
+// BodyMarshaler 请求包体按字段顺序写入Writer
+type BodyMarshaler interface {
+	MarshalBody(w *Writer)
+}
+
+// BodyUnmarshaler 响应包体按字段顺序从Reader读取
+// 读取越界时Reader记录错误并返回零值，由调用方统一检查Err
+type BodyUnmarshaler interface {
+	UnmarshalBody(r *Reader)
+}
+
+// Writer 小端序写入器，写入的数据追加到内部切片
+type Writer struct {
+	buf []byte
+}
+
+// NewWriter 创建写入器，buf为预分配的空间，写入从其末尾开始
+func NewWriter(buf []byte) *Writer {
+	return &Writer{buf: buf}
+}
+
+// Bytes 返回已写入的数据
+func (w *Writer) Bytes() []byte {
+	return w.buf
+}
+
+// Len 返回已写入的长度
+func (w *Writer) Len() int {
+	return len(w.buf)
+}
+
+func (w *Writer) Uint8(v uint8) {
+	w.buf = append(w.buf, v)
+}
+
+func (w *Writer) Uint16(v uint16) {
+	w.buf = append(w.buf, byte(v), byte(v>>8))
+}
+
+func (w *Writer) Uint32(v uint32) {
+	w.buf = append(w.buf, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+// Raw 写入原始字节
+func (w *Writer) Raw(b []byte) {
+	w.buf = append(w.buf, b...)
+}
+
+// VarInt 写入通达信变长整数，格式见Reader.VarInt
+func (w *Writer) VarInt(v int) {
+	b := byte(0)
+	if v < 0 {
+		b = 0x40
+		v = -v
+	}
+	b |= byte(v & 0x3f)
+	for v >>= 6; v > 0; v >>= 7 {
+		w.buf = append(w.buf, b|0x80)
+		b = byte(v & 0x7f)
+	}
+	w.buf = append(w.buf, b)
+}
+
+// Fixed 写入定长字段，b不足n字节时以0补齐，超出部分截断
+func (w *Writer) Fixed(b []byte, n int) {
+	if len(b) > n {
+		b = b[:n]
+	}
+	w.buf = append(w.buf, b...)
+	for i := len(b); i < n; i++ {
+		w.buf = append(w.buf, 0)
+	}
+}
+
+// Reader 带游标的小端序读取器
+// 首次越界后记录错误，之后的读取均返回零值，解析完成后检查Err即可
+type Reader struct {
+	data []byte
+	off  int
+	err  error
+}
+
+// NewReader 创建读取器，data在读取期间不可修改
+func NewReader(data []byte) *Reader {
+	return &Reader{data: data}
+}
+
+// Reset 复用读取器解析新的数据
+func (r *Reader) Reset(data []byte) {
+	r.data = data
+	r.off = 0
+	r.err = nil
+}
+
+// Err 返回首个读取错误
+func (r *Reader) Err() error {
+	return r.err
+}
+
+// Offset 返回当前游标位置，出错时为出错字段的起始位置
+func (r *Reader) Offset() int {
+	return r.off
+}
+
+// Len 返回剩余未读的长度
+func (r *Reader) Len() int {
+	return len(r.data) - r.off
+}
+
+// next 前移游标n字节并返回这段数据，越界时记录错误并返回nil
+func (r *Reader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.data)-r.off {
+		r.err = fmt.Errorf("读取%d字节, 剩余%d字节: %w", n, len(r.data)-r.off, io.ErrUnexpectedEOF)
+		return nil
+	}
+	b := r.data[r.off : r.off+n]
+	r.off += n
+	return b
+}
+
+func (r *Reader) Uint8() uint8 {
+	b := r.next(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *Reader) Uint16() uint16 {
+	b := r.next(2)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(b)
+}
+
+func (r *Reader) Uint32() uint32 {
+	b := r.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+// VarInt 读取通达信变长整数，行情中的价格与成交量均以此编码
+// 首字节低6位为数值、0x40为符号位，0x80表示后续还有字节，后续字节每字节携带7位
+func (r *Reader) VarInt() int {
+	b := r.Uint8()
+	v := int(b & 0x3f)
+	neg := b&0x40 != 0
+	for shift := uint(6); b&0x80 != 0 && r.err == nil; shift += 7 {
+		if shift > 62 {
+			r.err = fmt.Errorf("变长整数超过64位")
+			return 0
+		}
+		b = r.Uint8()
+		v += int(b&0x7f) << shift
+	}
+	if neg {
+		v = -v
+	}
+	return v
+}
+
+// Raw 读取n字节，返回的切片引用原数据，需要保留时必须复制
+func (r *Reader) Raw(n int) []byte {
+	return r.next(n)
+}
+
+// Skip 跳过n字节
+func (r *Reader) Skip(n int) {
+	r.next(n)
+}
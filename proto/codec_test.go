@@ -0,0 +1,85 @@
+package proto
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	w := NewWriter(nil)
+	w.Uint8(0x01)
+	w.Uint16(0x0302)
+	w.Uint32(0x07060504)
+	w.Raw([]byte{0x08})
+	w.Fixed([]byte{0x09, 0x0a, 0x0b}, 2)
+	w.Fixed([]byte{0x0c}, 3)
+	assert.Equal(t, []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 12, 0, 0}, w.Bytes())
+}
+
+func TestReader(t *testing.T) {
+	r := NewReader([]byte{1, 2, 3, 4, 5, 6, 7, 8, 9})
+	assert.Equal(t, uint8(0x01), r.Uint8())
+	assert.Equal(t, uint16(0x0302), r.Uint16())
+	assert.Equal(t, uint32(0x07060504), r.Uint32())
+	assert.NoError(t, r.Err())
+	assert.Equal(t, 2, r.Len())
+
+	// 越界后保持出错位置，后续读取均为零值
+	assert.Equal(t, uint32(0), r.Uint32())
+	assert.Equal(t, uint8(0), r.Uint8())
+	assert.Nil(t, r.Raw(1))
+	assert.True(t, errors.Is(r.Err(), io.ErrUnexpectedEOF))
+	assert.Equal(t, 7, r.Offset())
+}
+
+func TestVarInt(t *testing.T) {
+	// 单字节正负数与多字节编码
+	r := NewReader([]byte{0x3f, 0x7f, 0x80, 0x01, 0xc1, 0x8f, 0x04})
+	assert.Equal(t, 63, r.VarInt())
+	assert.Equal(t, -63, r.VarInt())
+	assert.Equal(t, 64, r.VarInt())
+	assert.Equal(t, -(1 + 0x0f<<6 + 4<<13), r.VarInt())
+	assert.NoError(t, r.Err())
+
+	w := NewWriter(nil)
+	values := []int{0, 1, -1, 63, 64, -8191, 1234567, -987654321}
+	for _, v := range values {
+		w.VarInt(v)
+	}
+	r = NewReader(w.Bytes())
+	for _, v := range values {
+		assert.Equal(t, v, r.VarInt())
+	}
+	assert.Equal(t, 0, r.Len())
+
+	// 数据截断
+	r = NewReader([]byte{0x80, 0x80})
+	r.VarInt()
+	assert.True(t, errors.Is(r.Err(), io.ErrUnexpectedEOF))
+}
+
+type codecBody struct {
+	A uint16
+	B uint32
+}
+
+func (b *codecBody) UnmarshalBody(r *Reader) {
+	b.A = r.Uint16()
+	b.B = r.Uint32()
+}
+
+func TestDefaultUnmarshal(t *testing.T) {
+	var body codecBody
+	err := DefaultUnmarshal([]byte{1, 0, 2, 0}, &body)
+	var decodeErr *DecodeError
+	if assert.True(t, errors.As(err, &decodeErr)) {
+		assert.Empty(t, decodeErr.Command)
+		assert.Equal(t, 2, decodeErr.Offset)
+	}
+	assert.True(t, errors.Is(err, ErrDecode))
+
+	assert.NoError(t, DefaultUnmarshal([]byte{1, 0, 2, 0, 0, 0}, &body))
+	assert.Equal(t, codecBody{A: 1, B: 2}, body)
+}
@@ -0,0 +1,59 @@
+package proto
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrBadHeader 响应包头无法解析或内容非法
+	ErrBadHeader = errors.New("响应包头无效")
+	// ErrDecompress 响应体zlib解压失败
+	ErrDecompress = errors.New("响应体解压失败")
+	// ErrDecode 响应体无法解析为对应的结构，具体信息见DecodeError
+	ErrDecode = errors.New("响应体解析失败")
+	// ErrMismatch 响应的序号或指令号与请求不符，具体信息见MismatchError
+	ErrMismatch = errors.New("响应与请求不匹配")
+)
+
+// DecodeError 响应体解析失败，记录出错的指令与字节偏移
+type DecodeError struct {
+	// Command 指令名称，见CommandName，脱离客户端直接解析时为空
+	Command string
+	Offset  int
+	Err     error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Command == "" {
+		return fmt.Sprintf("%v: 偏移%d: %v", ErrDecode, e.Offset, e.Err)
+	}
+	return fmt.Sprintf("%v: %s 偏移%d: %v", ErrDecode, e.Command, e.Offset, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Is 使errors.Is(err, ErrDecode)成立
+func (e *DecodeError) Is(target error) bool {
+	return target == ErrDecode
+}
+
+// MismatchError 响应包头的序号或指令号与请求不符，通常意味着数据流已错位
+type MismatchError struct {
+	SeqID           uint32
+	Command         CommandID
+	ResponseSeqID   uint32
+	ResponseCommand CommandID
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("%v: 请求序号%d指令%v, 响应序号%d指令%v",
+		ErrMismatch, e.SeqID, e.Command, e.ResponseSeqID, e.ResponseCommand)
+}
+
+// Is 使errors.Is(err, ErrMismatch)成立
+func (e *MismatchError) Is(target error) bool {
+	return target == ErrMismatch
+}
@@ -0,0 +1,123 @@
+package proto
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// CommandID 指令号，位于请求包头与响应包头中
+type CommandID uint16
+
+func (c CommandID) String() string {
+	return fmt.Sprintf("0x%04x", uint16(c))
+}
+
+const (
+	// RequestFlag 请求包头的起始标志
+	RequestFlag = 0x0c
+	// RequestHeaderSize 请求包头长度，包含指令号
+	RequestHeaderSize = 0x0c
+	// PacketHeaderSize 响应包头长度
+	PacketHeaderSize = 0x10
+	// ResponsePrefix 响应包头的起始标志
+	ResponsePrefix = 0x0074cbb1
+)
+
+// RequestHeader 请求包头
+// 包头之后紧跟包体，ZipSize与UnzipSize均为指令号与包体的长度之和
+type RequestHeader struct {
+	Flag       uint8     `json:"flag"`
+	SeqID      uint32    `json:"seq_id"`
+	PacketType uint8     `json:"packet_type"`
+	ZipSize    uint16    `json:"zip_size"`
+	UnzipSize  uint16    `json:"unzip_size"`
+	Command    CommandID `json:"command"`
+}
+
+// NewRequestHeader 创建指定指令号的请求包头，SeqID由发送请求的客户端在序列化后的数据中分配
+func NewRequestHeader(command CommandID) RequestHeader {
+	return RequestHeader{
+		Flag:       RequestFlag,
+		PacketType: 0x01,
+		Command:    command,
+	}
+}
+
+// Request 以RequestHeader建模的请求，客户端据此分配序号并校验响应是否匹配
+// 客户端不修改请求本身，而是将序号写入Marshal返回的数据，因此Marshal每次须返回新的切片
+type Request interface {
+	Marshaler
+	RequestHeader() *RequestHeader
+}
+
+// MarshalRequest 序列化请求包头与包体，长度字段按包体长度填写，header本身不被修改，可在多个协程中并发序列化同一请求
+func MarshalRequest(header *RequestHeader, body BodyMarshaler) ([]byte, error) {
+	w := NewWriter(make([]byte, RequestHeaderSize, 64))
+	body.MarshalBody(w)
+	size := uint16(w.Len() - RequestHeaderSize + 2)
+	data := w.Bytes()
+	data[0] = header.Flag
+	binary.LittleEndian.PutUint32(data[1:], header.SeqID)
+	data[5] = header.PacketType
+	binary.LittleEndian.PutUint16(data[6:], size)
+	binary.LittleEndian.PutUint16(data[8:], size)
+	binary.LittleEndian.PutUint16(data[10:], uint16(header.Command))
+	return data, nil
+}
+
+// PacketHeader 响应包头
+type PacketHeader struct {
+	raw        [PacketHeaderSize]byte
+	Prefix     uint32    `json:"prefix"`
+	Flag       uint8     `json:"flag"`
+	SeqID      uint32    `json:"seq_id"`
+	PacketType uint8     `json:"packet_type"`
+	Command    CommandID `json:"command"`
+	// ZipSize 包体传输长度，与UnzipSize不等时包体经过zlib压缩
+	ZipSize   int `json:"zip_size"`
+	UnzipSize int `json:"unzip_size"`
+}
+
+func (h *PacketHeader) Bytes() []byte {
+	return h.raw[:]
+}
+
+func (h *PacketHeader) Compressed() bool {
+	return h.ZipSize != h.UnzipSize
+}
+
+func (h *PacketHeader) Size() int {
+	return h.UnzipSize
+}
+
+// Unmarshal 解析响应包头，位于每个响应的接收路径上，直接按偏移解码以避免反射与内存分配
+func (h *PacketHeader) Unmarshal(data []byte) error {
+	if len(data) != PacketHeaderSize {
+		return fmt.Errorf("%w: 长度%d", ErrBadHeader, len(data))
+	}
+	copy(h.raw[:], data)
+	h.Prefix = binary.LittleEndian.Uint32(data[0:])
+	h.Flag = data[4]
+	h.SeqID = binary.LittleEndian.Uint32(data[5:])
+	h.PacketType = data[9]
+	h.Command = CommandID(binary.LittleEndian.Uint16(data[10:]))
+	h.ZipSize = int(binary.LittleEndian.Uint16(data[12:]))
+	h.UnzipSize = int(binary.LittleEndian.Uint16(data[14:]))
+	if h.Prefix != ResponsePrefix {
+		return fmt.Errorf("%w: 起始标志%08x", ErrBadHeader, h.Prefix)
+	}
+	return nil
+}
+
+// Match 校验响应是否对应请求的序号与指令号
+func (h *PacketHeader) Match(req *RequestHeader) error {
+	if h.SeqID != req.SeqID || h.Command != req.Command {
+		return &MismatchError{
+			SeqID:           req.SeqID,
+			Command:         req.Command,
+			ResponseSeqID:   h.SeqID,
+			ResponseCommand: h.Command,
+		}
+	}
+	return nil
+}
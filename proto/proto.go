@@ -1,9 +1,8 @@
 package proto
 
 import (
-	"bytes"
-	"github.com/lunixbochs/struc"
-	"log"
+	"fmt"
+	"strings"
 )
 
 type Factory func() (Marshaler, Unmarshaler, error)
@@ -12,64 +11,72 @@ type Marshaler interface {
 	Marshal() ([]byte, error)
 }
 
+// Unmarshaler 响应包体解析
+// 传入的数据归Unmarshaler所有，可在返回后继续引用，见BufferSafe
 type Unmarshaler interface {
 	Unmarshal([]byte) error
 }
 
-// 行情服务器发送第一条指令的返回数据
-type PacketHeader struct {
-	raw      []byte
-	Unknown1 uint `struc:"uint32,little";json:"unknown_1"`
-
-	// B will be encoded/decoded as a 16-bit int (a "short")
-	// but is stored as a native int in the struct
-	Unknown2 uint `struc:"uint32,little";json:"unknown_2"`
-
-	// the sizeof key links a buffer's size to any int field
-	Unknown3 uint `struc:"uint32,little";json:"unknown_3"`
-	ZipSize  int  `struc:"uint16,little";json:"zip_size"`
+// BufferSafe 响应可实现该接口声明Unmarshal返回后不再引用传入的数据
+// 客户端只将缓冲池中的数据直接交给声明安全的响应，其余响应收到的是副本
+type BufferSafe interface {
+	BufferSafe() bool
+}
 
-	// you can get freaky if you want
-	UnzipSize int `struc:"uint16,little";json:"unzip_size"`
+// IsBufferSafe 判断响应能否直接解析缓冲池中的数据
+func IsBufferSafe(u Unmarshaler) bool {
+	if b, ok := u.(BufferSafe); ok {
+		return b.BufferSafe()
+	}
+	return false
 }
 
-func (h *PacketHeader) Bytes() []byte {
-	return h.raw
+// Commander 请求可实现该接口返回指令名称，用于日志、统计、拦截器等
+type Commander interface {
+	Command() string
 }
 
-func (h *PacketHeader) Compressed() bool {
-	return h.ZipSize != h.UnzipSize
+// CommandName 返回请求的指令名称，未实现Commander时使用类型名
+func CommandName(m Marshaler) string {
+	if c, ok := m.(Commander); ok {
+		return c.Command()
+	}
+	name := fmt.Sprintf("%T", m)
+	name = name[strings.LastIndex(name, ".")+1:]
+	return strings.TrimSuffix(name, "Request")
 }
 
-func (h *PacketHeader) Size() int {
-	return h.UnzipSize
+// Idempotent 请求可实现该接口声明自身能否在连接中断后重放
+// 行情查询指令均为只读操作，未实现该接口的请求视为可重放
+type Idempotent interface {
+	Idempotent() bool
 }
-func (h *PacketHeader) Unmarshal(data []byte) error {
-	h.raw = data
-	return DefaultUnmarshal(data, h)
+
+// IsIdempotent 判断请求能否在重连后重放
+func IsIdempotent(m Marshaler) bool {
+	if i, ok := m.(Idempotent); ok {
+		return i.Idempotent()
+	}
+	return true
 }
 
-// 基于struc包的反序列化方案
-func DefaultUnmarshal(data []byte, v interface{}) error {
-	// 构造流
-	buf := bytes.NewBuffer(data)
-	// 使用struc解析到struct
-	err := struc.Unpack(buf, v)
-	if err != nil {
-		log.Println(err)
-		return err
+// DefaultUnmarshal 按BodyUnmarshaler声明的布局解析响应包体
+// 解析失败时返回*DecodeError，其中记录出错字段的偏移，指令名称由发送请求的客户端填写
+func DefaultUnmarshal(data []byte, v BodyUnmarshaler) error {
+	r := NewReader(data)
+	v.UnmarshalBody(r)
+	if err := r.Err(); err != nil {
+		return &DecodeError{
+			Offset: r.Offset(),
+			Err:    err,
+		}
 	}
 	return nil
 }
 
-// 基于struc包的序列化方案
-func DefaultMarshal(v interface{}) ([]byte, error) {
-	// 构造流
-	buf := bytes.Buffer{}
-	// 使用struc解析到struct
-	err := struc.Pack(&buf, v)
-	if err != nil {
-		return nil, err
-	}
-	return buf.Bytes(), nil
+// DefaultMarshal 按BodyMarshaler声明的布局序列化
+func DefaultMarshal(v BodyMarshaler) ([]byte, error) {
+	w := NewWriter(nil)
+	v.MarshalBody(w)
+	return w.Bytes(), nil
 }
@@ -0,0 +1,134 @@
+package proto
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrUnknownCommand 注册表中没有该指令
+	ErrUnknownCommand = errors.New("未知指令")
+	// ErrDuplicateCommand 指令名称已被注册
+	ErrDuplicateCommand = errors.New("指令重复注册")
+)
+
+// Command 注册表中的指令描述
+type Command struct {
+	// Name 指令名称，与Commander.Command返回值一致
+	Name string
+	// ID 指令号，多个指令可共用同一指令号，如SetupCmd1与SetupCmd2
+	ID CommandID
+	// Params 返回新的参数结构指针，用于从JSON解码参数，无参数的指令为nil
+	Params func() interface{}
+	// Build 使用Params返回的参数创建请求与响应，无参数的指令传入nil
+	Build func(params interface{}) (Marshaler, Unmarshaler, error)
+}
+
+// Registry 指令注册表，按名称或指令号查找指令并根据参数创建请求
+type Registry struct {
+	mu     sync.RWMutex
+	byName map[string]*Command
+	byID   map[CommandID]*Command
+}
+
+// NewRegistry 创建空的注册表
+func NewRegistry() *Registry {
+	return &Registry{
+		byName: map[string]*Command{},
+		byID:   map[CommandID]*Command{},
+	}
+}
+
+// DefaultRegistry 默认注册表，各版本协议包在初始化时将指令注册于此
+var DefaultRegistry = NewRegistry()
+
+// Register 注册指令，名称重复时返回ErrDuplicateCommand
+// 指令号相同的多个指令中，先注册的作为按指令号查找的结果
+func (r *Registry) Register(cmd Command) error {
+	if cmd.Name == "" || cmd.Build == nil {
+		return fmt.Errorf("指令%q缺少名称或Build", cmd.Name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.byName[cmd.Name]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateCommand, cmd.Name)
+	}
+	r.byName[cmd.Name] = &cmd
+	if _, ok := r.byID[cmd.ID]; !ok {
+		r.byID[cmd.ID] = &cmd
+	}
+	return nil
+}
+
+// MustRegister 注册指令，失败时panic，用于包初始化
+func (r *Registry) MustRegister(cmd Command) {
+	if err := r.Register(cmd); err != nil {
+		panic(err)
+	}
+}
+
+// Lookup 按名称查找指令
+func (r *Registry) Lookup(name string) (*Command, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cmd, ok := r.byName[name]
+	return cmd, ok
+}
+
+// LookupID 按指令号查找指令，可用于根据包头解析抓包数据
+func (r *Registry) LookupID(id CommandID) (*Command, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cmd, ok := r.byID[id]
+	return cmd, ok
+}
+
+// Commands 返回按名称排序的全部指令
+func (r *Registry) Commands() []Command {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	commands := make([]Command, 0, len(r.byName))
+	for _, cmd := range r.byName {
+		commands = append(commands, *cmd)
+	}
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].Name < commands[j].Name
+	})
+	return commands
+}
+
+// Build 按名称创建请求与响应，params为JSON格式的参数，无参数的指令可传入nil
+// 参数中出现未知字段时返回错误，以便发现拼写错误
+func (r *Registry) Build(name string, params []byte) (Marshaler, Unmarshaler, error) {
+	cmd, ok := r.Lookup(name)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s", ErrUnknownCommand, name)
+	}
+	var p interface{}
+	if cmd.Params != nil {
+		p = cmd.Params()
+		if len(bytes.TrimSpace(params)) > 0 {
+			decoder := json.NewDecoder(bytes.NewReader(params))
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(p); err != nil {
+				return nil, nil, fmt.Errorf("%s 参数无效: %w", name, err)
+			}
+		}
+	}
+	return cmd.Build(p)
+}
+
+// Factory 返回按名称与JSON参数创建请求的Factory，参数在此时即完成校验
+func (r *Registry) Factory(name string, params []byte) (Factory, error) {
+	if _, _, err := r.Build(name, params); err != nil {
+		return nil, err
+	}
+	params = append([]byte(nil), params...)
+	return func() (Marshaler, Unmarshaler, error) {
+		return r.Build(name, params)
+	}, nil
+}
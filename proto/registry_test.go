@@ -0,0 +1,66 @@
+package proto
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type echoParams struct {
+	Value int `json:"value"`
+}
+
+type echoRequest struct {
+	Value int
+}
+
+func (req *echoRequest) Marshal() ([]byte, error) {
+	return []byte{byte(req.Value)}, nil
+}
+
+type echoResponse struct{}
+
+func (resp *echoResponse) Unmarshal([]byte) error {
+	return nil
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	echo := Command{
+		Name:   "Echo",
+		ID:     0x0001,
+		Params: func() interface{} { return new(echoParams) },
+		Build: func(p interface{}) (Marshaler, Unmarshaler, error) {
+			return &echoRequest{Value: p.(*echoParams).Value}, &echoResponse{}, nil
+		},
+	}
+	assert.NoError(t, r.Register(echo))
+	assert.True(t, errors.Is(r.Register(echo), ErrDuplicateCommand))
+	echo.Name = "Echo2"
+	assert.NoError(t, r.Register(echo))
+
+	// 指令号相同时返回先注册的指令
+	cmd, ok := r.LookupID(0x0001)
+	if assert.True(t, ok) {
+		assert.Equal(t, "Echo", cmd.Name)
+	}
+	assert.Equal(t, 2, len(r.Commands()))
+
+	req, _, err := r.Build("Echo", []byte(`{"value": 7}`))
+	assert.NoError(t, err)
+	assert.Equal(t, &echoRequest{Value: 7}, req)
+	req, _, err = r.Build("Echo", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, &echoRequest{}, req)
+
+	_, _, err = r.Build("Echo", []byte(`{"valeu": 7}`))
+	assert.Error(t, err)
+	_, _, err = r.Build("Missing", nil)
+	assert.True(t, errors.Is(err, ErrUnknownCommand))
+
+	factory, err := r.Factory("Echo", []byte(`{"value": 3}`))
+	if assert.NoError(t, err) {
+		req, _, _ := factory()
+		assert.Equal(t, &echoRequest{Value: 3}, req)
+	}
+}
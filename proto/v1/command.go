@@ -0,0 +1,14 @@
+package v1
+
+import "github.com/cyclegen-community/tdx-go/proto"
+
+// 指令号
+const (
+	CmdSetupCmd1         proto.CommandID = 0x000d
+	CmdSetupCmd2         proto.CommandID = 0x000d
+	CmdSetupCmd3         proto.CommandID = 0x0fdb
+	CmdGetSecurityCount  proto.CommandID = 0x044e
+	CmdGetSecurityList   proto.CommandID = 0x0450
+	CmdGetSecurityQuotes proto.CommandID = 0x053e
+	CmdGetIndexBars      proto.CommandID = 0x052d
+)
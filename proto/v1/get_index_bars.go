@@ -0,0 +1,177 @@
+package v1
+
+// 获取指数K线
+import (
+	"errors"
+	"fmt"
+	"github.com/cyclegen-community/tdx-go/proto"
+	"github.com/cyclegen-community/tdx-go/utils/parse"
+	"time"
+)
+
+// KLineCategory K线周期
+type KLineCategory int
+
+const (
+	KLine5Min KLineCategory = iota
+	KLine15Min
+	KLine30Min
+	KLine1Hour
+	KLineDaily
+	KLineWeekly
+	KLineMonthly
+	KLineExtended1Min
+	KLine1Min
+	// KLineDay 日线，pytdx中的KLINE_TYPE_RI_K
+	KLineDay
+	KLine3Month
+	KLineYearly
+)
+
+// MaxBars 单个请求包最多获取的K线数量
+const MaxBars = 800
+
+// 单条K线记录的最小长度，所有变长整数均为1字节
+const minBarSize = 20
+
+// ErrBarCount 单个请求包中的K线数量不在1到MaxBars之间
+var ErrBarCount = errors.New("K线数量超出单个请求包的允许范围")
+
+// 交易所所在时区，K线时间均为北京时间
+var exchangeLocation = time.FixedZone("CST", 8*60*60)
+
+// 请求包结构
+type GetIndexBarsRequest struct {
+	Header   proto.RequestHeader `json:"header"`
+	Category KLineCategory       `json:"category"`
+	Market   Market              `json:"market"`
+	Code     string              `json:"code"`
+	// Start 距最新一根K线的偏移，0表示从最新的K线开始向前获取
+	Start int `json:"start"`
+	Count int `json:"count"`
+}
+
+// 请求包序列化输出
+func (req *GetIndexBarsRequest) Marshal() ([]byte, error) {
+	return proto.MarshalRequest(&req.Header, req)
+}
+
+// 请求包体布局，对应pytdx中的struct.pack("<H6sHHHHIIH", market, code, category, 1, start, count, 0, 0, 0)
+func (req *GetIndexBarsRequest) MarshalBody(w *proto.Writer) {
+	w.Uint16(uint16(req.Market))
+	w.Fixed([]byte(req.Code), 6)
+	w.Uint16(uint16(req.Category))
+	w.Uint16(1)
+	w.Uint16(uint16(req.Start))
+	w.Uint16(uint16(req.Count))
+	w.Uint32(0)
+	w.Uint32(0)
+	w.Uint16(0)
+}
+
+// 请求包头
+func (req *GetIndexBarsRequest) RequestHeader() *proto.RequestHeader {
+	return &req.Header
+}
+
+// 指令名称
+func (req *GetIndexBarsRequest) Command() string {
+	return "GetIndexBars"
+}
+
+// IndexBar 指数K线，价格单位为点
+type IndexBar struct {
+	DateTime  time.Time `json:"datetime"`
+	Open      float64   `json:"open"`
+	Close     float64   `json:"close"`
+	High      float64   `json:"high"`
+	Low       float64   `json:"low"`
+	Vol       float64   `json:"vol"`
+	Amount    float64   `json:"amount"`
+	UpCount   int       `json:"up_count"`
+	DownCount int       `json:"down_count"`
+}
+
+// 响应包结构
+type GetIndexBarsResponse struct {
+	Count int        `json:"count"`
+	Bars  []IndexBar `json:"bars"`
+	// 时间字段的格式取决于K线周期
+	category KLineCategory
+}
+
+func (resp *GetIndexBarsResponse) Unmarshal(data []byte) error {
+	return proto.DefaultUnmarshal(data, resp)
+}
+
+// 解析结果不引用响应数据，可直接解析缓冲池中的数据
+func (resp *GetIndexBarsResponse) BufferSafe() bool {
+	return true
+}
+
+// readBarTime 读取K线时间，分钟级K线为压缩的日期与分钟数，其余为yyyymmdd，见pytdx的get_datetime
+func readBarTime(r *proto.Reader, category KLineCategory) time.Time {
+	if category < KLineDaily || category == KLineExtended1Min || category == KLine1Min {
+		zipDay, minutes := int(r.Uint16()), int(r.Uint16())
+		year := zipDay>>11 + 2004
+		month := zipDay % 2048 / 100
+		day := zipDay % 2048 % 100
+		return time.Date(year, time.Month(month), day, minutes/60, minutes%60, 0, 0, exchangeLocation)
+	}
+	zipDay := int(r.Uint32())
+	return time.Date(zipDay/10000, time.Month(zipDay%10000/100), zipDay%100, 15, 0, 0, 0, exchangeLocation)
+}
+
+// 价格以千分之一点为单位，开盘价相对上一根K线的收盘价编码，其余相对开盘价编码
+func calPrice1000(base, diff int) float64 {
+	return float64(base+diff) / 1000
+}
+
+// 响应包体布局：uint16记录数，其后为变长记录，参照pytdx的GetIndexBarsCmd.parseResponse
+func (resp *GetIndexBarsResponse) UnmarshalBody(r *proto.Reader) {
+	count := int(r.Uint16())
+	if r.Err() != nil || count*minBarSize > r.Len() {
+		r.Skip(count * minBarSize)
+		return
+	}
+	resp.Bars = make([]IndexBar, count)
+	var base int
+	for idx := range resp.Bars {
+		bar := &resp.Bars[idx]
+		bar.DateTime = readBarTime(r, resp.category)
+		open := base + r.VarInt()
+		closeDiff := r.VarInt()
+		bar.Open = calPrice1000(open, 0)
+		bar.Close = calPrice1000(open, closeDiff)
+		bar.High = calPrice1000(open, r.VarInt())
+		bar.Low = calPrice1000(open, r.VarInt())
+		bar.Vol = parse.GetVolume(int(r.Uint32()))
+		bar.Amount = parse.GetVolume(int(r.Uint32()))
+		bar.UpCount = int(r.Uint16())
+		bar.DownCount = int(r.Uint16())
+		base = open + closeDiff
+	}
+	resp.Count = count
+}
+
+// 创建指数K线请求包，count需在1到MaxBars之间
+func NewGetIndexBarsRequest(category KLineCategory, market Market, code string, start, count int) (*GetIndexBarsRequest, error) {
+	if count <= 0 || count > MaxBars {
+		return nil, fmt.Errorf("%w: %d", ErrBarCount, count)
+	}
+	request := &GetIndexBarsRequest{
+		Header:   proto.NewRequestHeader(CmdGetIndexBars),
+		Category: category,
+		Market:   market,
+		Code:     code,
+		Start:    start,
+		Count:    count,
+	}
+	return request, nil
+}
+
+func NewGetIndexBars(category KLineCategory, market Market, code string, start, count int) (*GetIndexBarsRequest, *GetIndexBarsResponse, error) {
+	response := GetIndexBarsResponse{category: category}
+	var request, err = NewGetIndexBarsRequest(category, market, code, start, count)
+	return request, &response, err
+}
@@ -0,0 +1,71 @@
+package v1
+
+import (
+	"errors"
+	"github.com/cyclegen-community/tdx-go/proto"
+	"github.com/cyclegen-community/tdx-go/utils"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestGetIndexBarsRequest_Marshal(t *testing.T) {
+	req, _, err := NewGetIndexBars(KLineDay, MarketShangHai, "000001", 0, 1)
+	assert.NoError(t, err)
+	data, err := req.Marshal()
+	assert.NoError(t, err)
+	assert.Equal(t, utils.HexString2Bytes("0c 00 00 00 00 01 1c 00 1c 00 2d 05 01 00 30 30 30 30 30 31"+
+		"09 00 01 00 00 00 01 00 00 00 00 00 00 00 00 00 00 00"), data)
+
+	_, _, err = NewGetIndexBars(KLineDay, MarketShangHai, "000001", 0, MaxBars+1)
+	assert.True(t, errors.Is(err, ErrBarCount))
+}
+
+func TestGetIndexBarsResponse_Unmarshal(t *testing.T) {
+	w := proto.NewWriter(nil)
+	w.Uint16(2)
+	// 日线: 开3000.000 收3010.500 高3020.000 低2990.000
+	w.Uint32(20201016)
+	for _, v := range []int{3000000, 10500, 20000, -10000} {
+		w.VarInt(v)
+	}
+	w.Uint32(0)
+	w.Uint32(0)
+	w.Uint16(900)
+	w.Uint16(600)
+	// 次日开盘价相对前一日收盘价编码
+	w.Uint32(20201019)
+	for _, v := range []int{-500, 1000, 2000, -1000} {
+		w.VarInt(v)
+	}
+	w.Uint32(0)
+	w.Uint32(0)
+	w.Uint16(0)
+	w.Uint16(0)
+
+	_, resp, _ := NewGetIndexBars(KLineDay, MarketShangHai, "000001", 0, 2)
+	assert.NoError(t, resp.Unmarshal(w.Bytes()))
+	if assert.Equal(t, 2, resp.Count) {
+		bar := resp.Bars[0]
+		assert.Equal(t, time.Date(2020, 10, 16, 15, 0, 0, 0, exchangeLocation), bar.DateTime)
+		assert.Equal(t, 3000.0, bar.Open)
+		assert.Equal(t, 3010.5, bar.Close)
+		assert.Equal(t, 3020.0, bar.High)
+		assert.Equal(t, 2990.0, bar.Low)
+		assert.Equal(t, 900, bar.UpCount)
+		assert.Equal(t, 3010.0, resp.Bars[1].Open)
+		assert.Equal(t, 3011.0, resp.Bars[1].Close)
+	}
+
+	// 分钟线使用压缩的日期与分钟数
+	w = proto.NewWriter(nil)
+	w.Uint16(1)
+	w.Uint16(uint16((2020-2004)<<11 + 1016))
+	w.Uint16(9*60 + 35)
+	w.Raw(make([]byte, 16))
+	_, resp, _ = NewGetIndexBars(KLine5Min, MarketShangHai, "000001", 0, 1)
+	assert.NoError(t, resp.Unmarshal(w.Bytes()))
+	if assert.Equal(t, 1, resp.Count) {
+		assert.Equal(t, time.Date(2020, 10, 16, 9, 35, 0, 0, exchangeLocation), resp.Bars[0].DateTime)
+	}
+}
@@ -14,31 +14,57 @@ const (
 
 // 请求包结构
 type GetSecurityCountRequest struct {
-	Unknown1 []byte `struc:"[12]byte"`
-	Market   Market `struc:"uint16,little";json:"market"`
-	Unknown2 []byte `struc:"[4]byte"`
+	Header  proto.RequestHeader `json:"header"`
+	Market  Market              `json:"market"`
+	Unknown []byte              `json:"unknown"`
 }
 
 // 请求包序列化输出
 func (req *GetSecurityCountRequest) Marshal() ([]byte, error) {
-	return proto.DefaultMarshal(req)
+	return proto.MarshalRequest(&req.Header, req)
+}
+
+// 请求包体布局
+func (req *GetSecurityCountRequest) MarshalBody(w *proto.Writer) {
+	w.Uint16(uint16(req.Market))
+	w.Fixed(req.Unknown, 4)
+}
+
+// 请求包头
+func (req *GetSecurityCountRequest) RequestHeader() *proto.RequestHeader {
+	return &req.Header
+}
+
+// 指令名称
+func (req *GetSecurityCountRequest) Command() string {
+	return "GetSecurityCount"
 }
 
 // 响应包结构
 type GetSecurityCountResponse struct {
-	Count uint `struc:"uint16,little";json:"count"`
+	Count uint `json:"count"`
 }
 
 func (resp *GetSecurityCountResponse) Unmarshal(data []byte) error {
 	return proto.DefaultUnmarshal(data, resp)
 }
 
+// 解析结果不引用响应数据，可直接解析缓冲池中的数据
+func (resp *GetSecurityCountResponse) BufferSafe() bool {
+	return true
+}
+
+// 响应包体布局
+func (resp *GetSecurityCountResponse) UnmarshalBody(r *proto.Reader) {
+	resp.Count = uint(r.Uint16())
+}
+
 // todo: 检测market是否为合法值
 func NewGetSecurityCountRequest(market Market) (*GetSecurityCountRequest, error) {
 	request := &GetSecurityCountRequest{
-		Unknown1: utils.HexString2Bytes("0c 0c 18 6c 00 01 08 00 08 00 4e 04"),
-		Market:   market,
-		Unknown2: utils.HexString2Bytes("75 c7 33 01"),
+		Header:  proto.NewRequestHeader(CmdGetSecurityCount),
+		Market:  market,
+		Unknown: utils.HexString2Bytes("75 c7 33 01"),
 	}
 	return request, nil
 }
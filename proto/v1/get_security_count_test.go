@@ -1,6 +1,8 @@
 package v1
 
 import (
+	"github.com/cyclegen-community/tdx-go/utils"
+	"github.com/stretchr/testify/assert"
 	"testing"
 )
 
@@ -8,3 +10,10 @@ func TestNewSecurityCountRequest(t *testing.T) {
 	t.Log(NewGetSecurityCount(MarketShenZhen))
 	t.Log(NewGetSecurityCount(MarketShangHai))
 }
+
+func TestGetSecurityCountRequest_Marshal(t *testing.T) {
+	req, _, _ := NewGetSecurityCount(MarketShangHai)
+	data, err := req.Marshal()
+	assert.NoError(t, err)
+	assert.Equal(t, utils.HexString2Bytes("0c 00 00 00 00 01 08 00 08 00 4e 04 01 00 75 c7 33 01"), data)
+}
@@ -2,56 +2,76 @@ package v1
 
 // 获取股票列表
 import (
+	"bytes"
 	"github.com/cyclegen-community/tdx-go/proto"
-	"github.com/cyclegen-community/tdx-go/utils"
 	"github.com/cyclegen-community/tdx-go/utils/parse"
-	"log"
 )
 
 // 请求包结构
 type GetSecurityListRequest struct {
-	// struc不允许slice解析，只允许包含长度的array，该长度可根据hex字符串计算
-	Unknown1 []byte `struc:"[12]byte"`
-	// pytdx中使用struct.Pack进行反序列化
-	// 其中<H等价于这里的struc:"uint16,little"
-	// <I等价于struc:"uint32,little"
-	Market Market `struc:"uint16,little";json:"market"`
-	Start  int    `struc:"uint16,little";json:"start"`
+	Header proto.RequestHeader `json:"header"`
+	Market Market              `json:"market"`
+	Start  int                 `json:"start"`
 }
 
 // 请求包序列化输出
 func (req *GetSecurityListRequest) Marshal() ([]byte, error) {
-	return proto.DefaultMarshal(req)
+	return proto.MarshalRequest(&req.Header, req)
+}
+
+// 请求包体布局，对应pytdx中的struct.pack("<HH", market, start)
+func (req *GetSecurityListRequest) MarshalBody(w *proto.Writer) {
+	w.Uint16(uint16(req.Market))
+	w.Uint16(uint16(req.Start))
+}
+
+// 请求包头
+func (req *GetSecurityListRequest) RequestHeader() *proto.RequestHeader {
+	return &req.Header
+}
+
+// 指令名称
+func (req *GetSecurityListRequest) Command() string {
+	return "GetSecurityList"
 }
 
 // 响应包结构
 type getSecurityListResponseRaw struct {
-	Count     int        `struc:"uint16,little,sizeof=StocksRaw";json:"count"`
-	StocksRaw []stockRaw `struc:"[29]byte, little";json:"stocks_raw"`
+	StocksRaw []stockRaw `json:"stocks_raw"`
 }
+
+// 每条记录29字节
 type stockRaw struct {
-	Code           string `struc:"[6]byte,little";json:"code"`
-	VolUnit        int    `struc:"uint16,little";json:"vol_unit"`
-	Name           []byte `struc:"[8]byte,little";json:"name"`
-	ReversedBytes1 []byte `struc:"[4]byte,little";json:"reversed_bytes_1"`
-	DecimalPoint   int    `struc:"byte,little";json:"decimal_point"`
-	PreCloseRaw    int    `struc:"uint32,little";json:"pre_close_raw"`
-	ReversedBytes2 []byte `struc:"[4]byte,little";json:"reversed_bytes_2"`
+	Code           []byte `json:"code"`
+	VolUnit        int    `json:"vol_unit"`
+	Name           []byte `json:"name"`
+	ReversedBytes1 []byte `json:"reversed_bytes_1"`
+	DecimalPoint   int    `json:"decimal_point"`
+	PreCloseRaw    int    `json:"pre_close_raw"`
+	ReversedBytes2 []byte `json:"reversed_bytes_2"`
+}
+
+// 记录中的字节切片引用响应数据，Stocks中完成复制
+func (raw *stockRaw) UnmarshalBody(r *proto.Reader) {
+	raw.Code = r.Raw(6)
+	raw.VolUnit = int(r.Uint16())
+	raw.Name = r.Raw(8)
+	raw.ReversedBytes1 = r.Raw(4)
+	raw.DecimalPoint = int(r.Uint8())
+	raw.PreCloseRaw = int(r.Uint32())
+	raw.ReversedBytes2 = r.Raw(4)
 }
 
 func (resp *getSecurityListResponseRaw) Stocks() ([]Stock, error) {
-	var (
-		stocks []Stock
-	)
+	stocks := make([]Stock, 0, len(resp.StocksRaw))
 	// 后续处理
 	for idx := range resp.StocksRaw {
-		name, err := parse.DecodeGBK(resp.StocksRaw[idx].Name) // .rstrip("\x00")
+		name, err := parse.DecodeGBK(bytes.TrimRight(resp.StocksRaw[idx].Name, "\x00"))
 		if err != nil {
 			return nil, err
 		}
-		log.Println(resp.StocksRaw[idx].PreCloseRaw)
 		stocks = append(stocks, Stock{
-			Code:         resp.StocksRaw[idx].Code,
+			Code:         string(resp.StocksRaw[idx].Code),
 			VolUnit:      resp.StocksRaw[idx].VolUnit,
 			DecimalPoint: resp.StocksRaw[idx].DecimalPoint,
 			Name:         string(name),
@@ -61,7 +81,20 @@ func (resp *getSecurityListResponseRaw) Stocks() ([]Stock, error) {
 	return stocks, nil
 }
 func (resp *getSecurityListResponseRaw) Unmarshal(data []byte) error {
-	return proto.DefaultUnmarshal(data, &resp)
+	return proto.DefaultUnmarshal(data, resp)
+}
+
+// 响应包体布局：uint16记录数，其后为定长记录
+func (resp *getSecurityListResponseRaw) UnmarshalBody(r *proto.Reader) {
+	count := int(r.Uint16())
+	if r.Err() != nil || count*29 > r.Len() {
+		r.Skip(count * 29)
+		return
+	}
+	resp.StocksRaw = make([]stockRaw, count)
+	for idx := range resp.StocksRaw {
+		resp.StocksRaw[idx].UnmarshalBody(r)
+	}
 }
 
 type Stock struct {
@@ -74,8 +107,8 @@ type Stock struct {
 
 // 响应包结构
 type GetSecurityListResponse struct {
-	Count  int     `struc:"uint16,little,sizeof=Stocks";json:"count"`
-	Stocks []Stock `struc:"[29]byte, little";json:"stocks"`
+	Count  int     `json:"count"`
+	Stocks []Stock `json:"stocks"`
 }
 
 // 内部套用原始结构解析，外部为经过解析之后的响应信息
@@ -89,16 +122,22 @@ func (resp *GetSecurityListResponse) Unmarshal(data []byte) error {
 	if err != nil {
 		return err
 	}
+	resp.Count = len(stocks)
 	resp.Stocks = stocks
 	return nil
 }
 
+// 解析结果不引用响应数据，可直接解析缓冲池中的数据
+func (resp *GetSecurityListResponse) BufferSafe() bool {
+	return true
+}
+
 // todo: 检测market是否为合法值
 func NewGetSecurityListRequest(market Market, start int) (*GetSecurityListRequest, error) {
 	request := &GetSecurityListRequest{
-		Unknown1: utils.HexString2Bytes("0c 01 18 64 01 01 06 00 06 00 50 04"),
-		Market:   market,
-		Start:    start,
+		Header: proto.NewRequestHeader(CmdGetSecurityList),
+		Market: market,
+		Start:  start,
 	}
 	return request, nil
 }
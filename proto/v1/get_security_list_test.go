@@ -1 +1,145 @@
 package v1
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/cyclegen-community/tdx-go/proto"
+	"github.com/cyclegen-community/tdx-go/utils"
+	"github.com/cyclegen-community/tdx-go/utils/parse"
+	"github.com/lunixbochs/struc"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// buildSecurityList 构造包含count条记录的响应包体
+func buildSecurityList(count int) []byte {
+	name, _ := parse.EncodeGBK([]byte("浦发银行"))
+	data := make([]byte, 2, 2+count*29)
+	binary.LittleEndian.PutUint16(data, uint16(count))
+	for i := 0; i < count; i++ {
+		record := make([]byte, 29)
+		copy(record, "600000")
+		binary.LittleEndian.PutUint16(record[6:], 100)
+		copy(record[8:16], name)
+		record[20] = 2
+		binary.LittleEndian.PutUint32(record[21:], 0x41200000)
+		data = append(data, record...)
+	}
+	return data
+}
+
+func TestGetSecurityListRequest_Marshal(t *testing.T) {
+	req, _, _ := NewGetSecurityList(MarketShangHai, 1000)
+	data, err := req.Marshal()
+	assert.NoError(t, err)
+	assert.Equal(t, utils.HexString2Bytes("0c 00 00 00 00 01 06 00 06 00 50 04 01 00 e8 03"), data)
+}
+
+func TestGetSecurityListResponse_Unmarshal(t *testing.T) {
+	var resp GetSecurityListResponse
+	assert.NoError(t, resp.Unmarshal(buildSecurityList(2)))
+	assert.Equal(t, 2, resp.Count)
+	assert.Equal(t, Stock{
+		Code:         "600000",
+		VolUnit:      100,
+		DecimalPoint: 2,
+		Name:         "浦发银行",
+		PreClose:     parse.GetVolume(0x41200000),
+	}, resp.Stocks[1])
+
+	// 记录数与实际长度不符
+	data := buildSecurityList(2)
+	err := resp.Unmarshal(data[:len(data)-1])
+	var decodeErr *proto.DecodeError
+	if assert.True(t, errors.As(err, &decodeErr)) {
+		assert.Equal(t, 2, decodeErr.Offset)
+	}
+}
+
+// struc版本的响应结构，仅用于性能对比
+type strucSecurityList struct {
+	Count  int             `struc:"uint16,little,sizeof=Stocks"`
+	Stocks []strucStockRaw `struc:"little"`
+}
+
+type strucStockRaw struct {
+	Code           []byte `struc:"[6]byte"`
+	VolUnit        int    `struc:"uint16,little"`
+	Name           []byte `struc:"[8]byte"`
+	ReversedBytes1 []byte `struc:"[4]byte"`
+	DecimalPoint   int    `struc:"uint8"`
+	PreCloseRaw    int    `struc:"uint32,little"`
+	ReversedBytes2 []byte `struc:"[4]byte"`
+}
+
+func TestStrucSecurityList(t *testing.T) {
+	var raw strucSecurityList
+	assert.NoError(t, struc.Unpack(bytes.NewReader(buildSecurityList(2)), &raw))
+	assert.Equal(t, 2, len(raw.Stocks))
+	assert.Equal(t, []byte("600000"), raw.Stocks[1].Code)
+	assert.Equal(t, 0x41200000, raw.Stocks[1].PreCloseRaw)
+}
+
+func BenchmarkSecurityListUnmarshal_Codec(b *testing.B) {
+	data := buildSecurityList(1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var raw getSecurityListResponseRaw
+		if err := raw.Unmarshal(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSecurityListUnmarshal_Struc(b *testing.B) {
+	data := buildSecurityList(1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var raw strucSecurityList
+		if err := struc.Unpack(bytes.NewReader(data), &raw); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// struc版本的请求结构，仅用于性能对比
+type strucSecurityCountRequest struct {
+	Flag       uint8  `struc:"uint8"`
+	SeqID      uint32 `struc:"uint32,little"`
+	PacketType uint8  `struc:"uint8"`
+	ZipSize    uint16 `struc:"uint16,little"`
+	UnzipSize  uint16 `struc:"uint16,little"`
+	Command    uint16 `struc:"uint16,little"`
+	Market     int    `struc:"uint16,little"`
+	Unknown    []byte `struc:"[4]byte"`
+}
+
+func BenchmarkSecurityCountMarshal_Codec(b *testing.B) {
+	req, _, _ := NewGetSecurityCount(MarketShangHai)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := req.Marshal(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSecurityCountMarshal_Struc(b *testing.B) {
+	req := strucSecurityCountRequest{
+		Flag: 0x0c, SeqID: 0x006c180c, PacketType: 0x01, ZipSize: 8, UnzipSize: 8,
+		Command: uint16(CmdGetSecurityCount), Market: MarketShangHai,
+		Unknown: utils.HexString2Bytes("75 c7 33 01"),
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var buf bytes.Buffer
+		if err := struc.Pack(&buf, &req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
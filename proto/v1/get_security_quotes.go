@@ -1,100 +1,180 @@
 package v1
 
-//
-//// 请求包结构
-//type GetSecurityQuotesRequest struct {
-//	// struc不允许slice解析，只允许包含长度的array，该长度可根据hex字符串计算
-//	Unknown1 []byte `struc:"[12]byte"`
-//	// pytdx中使用struct.Pack进行反序列化
-//	// 其中<H等价于这里的struc:"uint16,little"
-//	// <I等价于struc:"uint32,little"
-//	Market   Market `struc:"uint16,little";json:"market"`
-//	Start int `struc:"uint16,little";json:"start"`
-//}
-//
-//// 请求包序列化输出
-//func (req *GetSecurityQuotesRequest) Marshal() ([]byte, error) {
-//	return proto.DefaultMarshal(req)
-//}
-//// 响应包结构
-//type getSecurityQuotesResponseRaw struct{
-//	Count int `struc:"uint16,little,sizeof=StocksRaw";json:"count"`
-//	StocksRaw []stockRaw `struc:"[29]byte, little";json:"stocks_raw"`
-//}
-//type stockRaw struct {
-//	Code string `struc:"[6]byte,little";json:"code"`
-//	VolUnit int `struc:"uint16,little";json:"vol_unit"`
-//	Name []byte `struc:"[8]byte,little";json:"name"`
-//	ReversedBytes1 []byte `struc:"[4]byte,little";json:"reversed_bytes_1"`
-//	DecimalPoint int `struc:"byte,little";json:"decimal_point"`
-//	PreCloseRaw int `struc:"uint32,little";json:"pre_close_raw"`
-//	ReversedBytes2 []byte `struc:"[4]byte,little";json:"reversed_bytes_2"`
-//}
-//func (resp *getSecurityQuotesResponseRaw) Stocks() ([]Stock, error) {
-//	var (
-//		stocks []Stock
-//	)
-//	// 后续处理
-//	for idx := range resp.StocksRaw{
-//		name, err := parse.DecodeGBK(resp.StocksRaw[idx].Name) // .rstrip("\x00")
-//		if err != nil {
-//			return nil, err
-//		}
-//		log.Println(resp.StocksRaw[idx].PreCloseRaw)
-//		stocks = append(stocks, Stock{
-//			Code:         resp.StocksRaw[idx].Code,
-//			VolUnit:      resp.StocksRaw[idx].VolUnit,
-//			DecimalPoint: resp.StocksRaw[idx].DecimalPoint,
-//			Name:         string(name),
-//			PreClose:     parse.GetVolume(resp.StocksRaw[idx].PreCloseRaw),
-//		})
-//	}
-//	return stocks, nil
-//}
-//func (resp *getSecurityQuotesResponseRaw) Unmarshal(data []byte) error {
-//	return proto.DefaultUnmarshal(data, &resp)
-//}
-//
-//// 响应包结构
-//type GetSecurityQuotesResponse struct{
-//	Count int `struc:"uint16,little,sizeof=Stocks";json:"count"`
-//	Stocks []Stock `struc:"[29]byte, little";json:"stocks"`
-//}
-//// 内部套用原始结构解析，外部为经过解析之后的响应信息
-//func (resp *GetSecurityQuotesResponse) Unmarshal(data []byte) error {
-//	var raw getSecurityQuotesResponseRaw
-//	err := raw.Unmarshal(data)
-//	if err != nil {
-//		return err
-//	}
-//	stocks, err := raw.Stocks()
-//	if err != nil {
-//		return err
-//	}
-//	resp.Stocks = stocks
-//	return nil
-//}
-//
-//type GetSecurityQuotesRequestParams struct {
-//	Market Market
-//	SecurityCode int
-//}
-//
-//// todo: 检测market是否为合法值
-//func NewGetSecurityQuotesRequest(securities []GetSecurityQuotesRequestParams) (*GetSecurityQuotesRequest, error) {
-//	for securityIdx := range securities{
-//		log.Println(securities[securityIdx].Market)
-//	}
-//	request := &GetSecurityQuotesRequest{
-//		Unknown1: utils.HexString2Bytes("0c 01 18 64 01 01 06 00 06 00 50 04"),
-//		Market: market,
-//		Start: start,
-//	}
-//	return request, nil
-//}
-//
-//func NewGetSecurityQuotes(market Market, start int) (*GetSecurityQuotesRequest, *GetSecurityQuotesResponse, error) {
-//	var response GetSecurityQuotesResponse
-//	var request, err = NewGetSecurityQuotesRequest(market, start)
-//	return request, &response, err
-//}
+// 获取实时行情
+import (
+	"errors"
+	"fmt"
+	"github.com/cyclegen-community/tdx-go/proto"
+	"github.com/cyclegen-community/tdx-go/utils/parse"
+)
+
+// MaxSecurityQuotes 单个请求包最多查询的证券数量
+const MaxSecurityQuotes = 80
+
+// 单条行情记录的最小长度，所有变长整数均为1字节
+const minQuoteSize = 56
+
+// ErrSecurityCount 单个请求包中的证券数量不在1到MaxSecurityQuotes之间
+var ErrSecurityCount = errors.New("证券数量超出单个请求包的允许范围")
+
+// Security 证券代码
+type Security struct {
+	Market Market `json:"market"`
+	Code   string `json:"code"`
+}
+
+func (s Security) String() string {
+	return fmt.Sprintf("%d:%s", s.Market, s.Code)
+}
+
+// 请求包结构
+type GetSecurityQuotesRequest struct {
+	Header     proto.RequestHeader `json:"header"`
+	Securities []Security          `json:"securities"`
+}
+
+// 请求包序列化输出
+func (req *GetSecurityQuotesRequest) Marshal() ([]byte, error) {
+	return proto.MarshalRequest(&req.Header, req)
+}
+
+// 请求包体布局，对应pytdx中的struct.pack("<HIHH", 5, 0, 0, count)与每只证券的struct.pack("<B6s", market, code)
+func (req *GetSecurityQuotesRequest) MarshalBody(w *proto.Writer) {
+	w.Uint16(0x0005)
+	w.Uint32(0)
+	w.Uint16(0)
+	w.Uint16(uint16(len(req.Securities)))
+	for _, security := range req.Securities {
+		w.Uint8(uint8(security.Market))
+		w.Fixed([]byte(security.Code), 6)
+	}
+}
+
+// 请求包头
+func (req *GetSecurityQuotesRequest) RequestHeader() *proto.RequestHeader {
+	return &req.Header
+}
+
+// 指令名称
+func (req *GetSecurityQuotesRequest) Command() string {
+	return "GetSecurityQuotes"
+}
+
+// SecurityQuote 单只证券的实时行情，价格单位为元
+type SecurityQuote struct {
+	Market     Market     `json:"market"`
+	Code       string     `json:"code"`
+	Active1    int        `json:"active1"`
+	Price      float64    `json:"price"`
+	LastClose  float64    `json:"last_close"`
+	Open       float64    `json:"open"`
+	High       float64    `json:"high"`
+	Low        float64    `json:"low"`
+	ServerTime int        `json:"server_time"`
+	Vol        int        `json:"vol"`
+	CurVol     int        `json:"cur_vol"`
+	Amount     float64    `json:"amount"`
+	SVol       int        `json:"s_vol"`
+	BVol       int        `json:"b_vol"`
+	BidPrices  [5]float64 `json:"bid_prices"`
+	AskPrices  [5]float64 `json:"ask_prices"`
+	BidVols    [5]int     `json:"bid_vols"`
+	AskVols    [5]int     `json:"ask_vols"`
+	Speed      float64    `json:"speed"`
+	Active2    int        `json:"active2"`
+}
+
+// Security 返回行情对应的证券代码
+func (q *SecurityQuote) Security() Security {
+	return Security{Market: q.Market, Code: q.Code}
+}
+
+// 价格以分为单位，开高低收等以相对现价的差值编码
+func calPrice(base, diff int) float64 {
+	return float64(base+diff) / 100
+}
+
+// 响应包体布局，参照pytdx的GetSecurityQuotesCmd.parseResponse
+func (q *SecurityQuote) UnmarshalBody(r *proto.Reader) {
+	q.Market = Market(r.Uint8())
+	q.Code = string(r.Raw(6))
+	q.Active1 = int(r.Uint16())
+	price := r.VarInt()
+	q.Price = calPrice(price, 0)
+	q.LastClose = calPrice(price, r.VarInt())
+	q.Open = calPrice(price, r.VarInt())
+	q.High = calPrice(price, r.VarInt())
+	q.Low = calPrice(price, r.VarInt())
+	q.ServerTime = r.VarInt()
+	r.VarInt()
+	q.Vol = r.VarInt()
+	q.CurVol = r.VarInt()
+	q.Amount = parse.GetVolume(int(r.Uint32()))
+	q.SVol = r.VarInt()
+	q.BVol = r.VarInt()
+	r.VarInt()
+	r.VarInt()
+	for level := 0; level < 5; level++ {
+		q.BidPrices[level] = calPrice(price, r.VarInt())
+		q.AskPrices[level] = calPrice(price, r.VarInt())
+		q.BidVols[level] = r.VarInt()
+		q.AskVols[level] = r.VarInt()
+	}
+	r.Skip(2)
+	r.VarInt()
+	r.VarInt()
+	r.VarInt()
+	r.VarInt()
+	q.Speed = float64(int16(r.Uint16())) / 100
+	q.Active2 = int(r.Uint16())
+}
+
+// 响应包结构
+type GetSecurityQuotesResponse struct {
+	Count  int             `json:"count"`
+	Quotes []SecurityQuote `json:"quotes"`
+}
+
+func (resp *GetSecurityQuotesResponse) Unmarshal(data []byte) error {
+	return proto.DefaultUnmarshal(data, resp)
+}
+
+// 解析结果不引用响应数据，可直接解析缓冲池中的数据
+func (resp *GetSecurityQuotesResponse) BufferSafe() bool {
+	return true
+}
+
+// 响应包体布局：2字节未知数据、uint16记录数，其后为变长记录
+// 服务器会略过不认识的证券，因此记录数可能少于请求数
+func (resp *GetSecurityQuotesResponse) UnmarshalBody(r *proto.Reader) {
+	r.Skip(2)
+	count := int(r.Uint16())
+	if r.Err() != nil || count*minQuoteSize > r.Len() {
+		r.Skip(count * minQuoteSize)
+		return
+	}
+	resp.Quotes = make([]SecurityQuote, count)
+	for idx := range resp.Quotes {
+		resp.Quotes[idx].UnmarshalBody(r)
+	}
+	resp.Count = count
+}
+
+// 创建实时行情请求包，securities的数量需在1到MaxSecurityQuotes之间
+func NewGetSecurityQuotesRequest(securities []Security) (*GetSecurityQuotesRequest, error) {
+	if len(securities) == 0 || len(securities) > MaxSecurityQuotes {
+		return nil, fmt.Errorf("%w: %d", ErrSecurityCount, len(securities))
+	}
+	request := &GetSecurityQuotesRequest{
+		Header:     proto.NewRequestHeader(CmdGetSecurityQuotes),
+		Securities: securities,
+	}
+	request.Header.PacketType = 0x02
+	return request, nil
+}
+
+func NewGetSecurityQuotes(securities []Security) (*GetSecurityQuotesRequest, *GetSecurityQuotesResponse, error) {
+	var response GetSecurityQuotesResponse
+	var request, err = NewGetSecurityQuotesRequest(securities)
+	return request, &response, err
+}
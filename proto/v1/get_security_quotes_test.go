@@ -0,0 +1,85 @@
+package v1
+
+import (
+	"errors"
+	"github.com/cyclegen-community/tdx-go/proto"
+	"github.com/cyclegen-community/tdx-go/utils"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// encodeQuote 按服务器的格式写入一条行情，价格以分为单位
+func encodeQuote(w *proto.Writer, security Security, price int) {
+	w.Uint8(uint8(security.Market))
+	w.Fixed([]byte(security.Code), 6)
+	w.Uint16(1)
+	w.VarInt(price)
+	for _, diff := range []int{-10, -5, 20, -30} { // 昨收、开、高、低
+		w.VarInt(diff)
+	}
+	w.VarInt(14999999)                            // 服务器时间
+	w.VarInt(0)                                   // 未知
+	w.VarInt(123456)                              // 总量
+	w.VarInt(100)                                 // 现量
+	w.Uint32(0x4e0f4240)                          // 金额
+	for _, v := range []int{60000, 63456, 0, 0} { // 外盘、内盘、未知
+		w.VarInt(v)
+	}
+	for level := 1; level <= 5; level++ {
+		w.VarInt(-level)
+		w.VarInt(level)
+		w.VarInt(level * 100)
+		w.VarInt(level * 200)
+	}
+	w.Uint16(0)
+	for i := 0; i < 4; i++ {
+		w.VarInt(0)
+	}
+	w.Uint16(uint16(0xffce)) // 涨速-0.5
+	w.Uint16(1)
+}
+
+func TestGetSecurityQuotesRequest_Marshal(t *testing.T) {
+	req, _, err := NewGetSecurityQuotes([]Security{{MarketShenZhen, "000001"}, {MarketShangHai, "600300"}})
+	assert.NoError(t, err)
+	data, err := req.Marshal()
+	assert.NoError(t, err)
+	assert.Equal(t, utils.HexString2Bytes("0c 00 00 00 00 02 1a 00 1a 00 3e 05 05 00 00 00 00 00 00 00 02 00"+
+		"00 30 30 30 30 30 31 01 36 30 30 33 30 30"), data)
+
+	_, _, err = NewGetSecurityQuotes(make([]Security, MaxSecurityQuotes+1))
+	assert.True(t, errors.Is(err, ErrSecurityCount))
+	_, _, err = NewGetSecurityQuotes(nil)
+	assert.True(t, errors.Is(err, ErrSecurityCount))
+}
+
+func TestGetSecurityQuotesResponse_Unmarshal(t *testing.T) {
+	w := proto.NewWriter(nil)
+	w.Uint16(0xcbb1)
+	w.Uint16(2)
+	encodeQuote(w, Security{MarketShenZhen, "000001"}, 1234)
+	encodeQuote(w, Security{MarketShangHai, "600300"}, 456)
+
+	var resp GetSecurityQuotesResponse
+	assert.NoError(t, resp.Unmarshal(w.Bytes()))
+	if assert.Equal(t, 2, resp.Count) {
+		quote := resp.Quotes[0]
+		assert.Equal(t, Security{MarketShenZhen, "000001"}, quote.Security())
+		assert.Equal(t, 12.34, quote.Price)
+		assert.Equal(t, 12.24, quote.LastClose)
+		assert.Equal(t, 12.29, quote.Open)
+		assert.Equal(t, 12.54, quote.High)
+		assert.Equal(t, 12.04, quote.Low)
+		assert.Equal(t, 123456, quote.Vol)
+		assert.Equal(t, [5]float64{12.33, 12.32, 12.31, 12.30, 12.29}, quote.BidPrices)
+		assert.Equal(t, [5]float64{12.35, 12.36, 12.37, 12.38, 12.39}, quote.AskPrices)
+		assert.Equal(t, [5]int{200, 400, 600, 800, 1000}, quote.AskVols)
+		assert.Equal(t, -0.5, quote.Speed)
+		assert.Equal(t, 4.56, resp.Quotes[1].Price)
+	}
+
+	// 记录被截断
+	data := w.Bytes()
+	err := resp.Unmarshal(data[:len(data)-1])
+	assert.True(t, errors.Is(err, proto.ErrDecode))
+}
@@ -0,0 +1,34 @@
+package v1
+
+// 心跳包
+// 服务器会断开长时间空闲的连接，参照pytdx使用查询股票数量指令保持连接活跃
+
+// 请求包结构
+type HeartbeatRequest struct {
+	GetSecurityCountRequest
+}
+
+// 指令名称
+func (req *HeartbeatRequest) Command() string {
+	return "Heartbeat"
+}
+
+// 响应包结构
+type HeartbeatResponse struct {
+	GetSecurityCountResponse
+}
+
+// 创建心跳请求包
+func NewHeartbeatRequest() (*HeartbeatRequest, error) {
+	request, err := NewGetSecurityCountRequest(MarketShenZhen)
+	if err != nil {
+		return nil, err
+	}
+	return &HeartbeatRequest{*request}, nil
+}
+
+func NewHeartbeat() (*HeartbeatRequest, *HeartbeatResponse, error) {
+	var response HeartbeatResponse
+	var request, err = NewHeartbeatRequest()
+	return request, &response, err
+}
@@ -0,0 +1,106 @@
+package v1
+
+import "github.com/cyclegen-community/tdx-go/proto"
+
+// 本包支持的全部指令，命令行工具、网关与抓包解析器共用此列表
+
+// GetSecurityCountParams 查询证券数量的参数
+type GetSecurityCountParams struct {
+	Market Market `json:"market"`
+}
+
+// GetSecurityListParams 查询证券列表的参数
+type GetSecurityListParams struct {
+	Market Market `json:"market"`
+	Start  int    `json:"start"`
+}
+
+// GetSecurityQuotesParams 查询实时行情的参数
+type GetSecurityQuotesParams struct {
+	Securities []Security `json:"securities"`
+}
+
+// GetIndexBarsParams 获取指数K线的参数
+type GetIndexBarsParams struct {
+	Category KLineCategory `json:"category"`
+	Market   Market        `json:"market"`
+	Code     string        `json:"code"`
+	Start    int           `json:"start"`
+	Count    int           `json:"count"`
+}
+
+func init() {
+	// 指令号相同时先注册的作为按指令号查找的结果
+	proto.DefaultRegistry.MustRegister(proto.Command{
+		Name: "SetupCmd1",
+		ID:   CmdSetupCmd1,
+		Build: func(interface{}) (req proto.Marshaler, resp proto.Unmarshaler, err error) {
+			req, resp, err = NewSetupCmd1()
+			return
+		},
+	})
+	proto.DefaultRegistry.MustRegister(proto.Command{
+		Name: "SetupCmd2",
+		ID:   CmdSetupCmd2,
+		Build: func(interface{}) (req proto.Marshaler, resp proto.Unmarshaler, err error) {
+			req, resp, err = NewSetupCmd2()
+			return
+		},
+	})
+	proto.DefaultRegistry.MustRegister(proto.Command{
+		Name: "SetupCmd3",
+		ID:   CmdSetupCmd3,
+		Build: func(interface{}) (req proto.Marshaler, resp proto.Unmarshaler, err error) {
+			req, resp, err = NewSetupCmd3()
+			return
+		},
+	})
+	proto.DefaultRegistry.MustRegister(proto.Command{
+		Name:   "GetSecurityCount",
+		ID:     CmdGetSecurityCount,
+		Params: func() interface{} { return new(GetSecurityCountParams) },
+		Build: func(p interface{}) (req proto.Marshaler, resp proto.Unmarshaler, err error) {
+			params := p.(*GetSecurityCountParams)
+			req, resp, err = NewGetSecurityCount(params.Market)
+			return
+		},
+	})
+	proto.DefaultRegistry.MustRegister(proto.Command{
+		Name: "Heartbeat",
+		ID:   CmdGetSecurityCount,
+		Build: func(interface{}) (req proto.Marshaler, resp proto.Unmarshaler, err error) {
+			req, resp, err = NewHeartbeat()
+			return
+		},
+	})
+	proto.DefaultRegistry.MustRegister(proto.Command{
+		Name:   "GetSecurityList",
+		ID:     CmdGetSecurityList,
+		Params: func() interface{} { return new(GetSecurityListParams) },
+		Build: func(p interface{}) (req proto.Marshaler, resp proto.Unmarshaler, err error) {
+			params := p.(*GetSecurityListParams)
+			req, resp, err = NewGetSecurityList(params.Market, params.Start)
+			return
+		},
+	})
+	proto.DefaultRegistry.MustRegister(proto.Command{
+		Name:   "GetSecurityQuotes",
+		ID:     CmdGetSecurityQuotes,
+		Params: func() interface{} { return new(GetSecurityQuotesParams) },
+		Build: func(p interface{}) (req proto.Marshaler, resp proto.Unmarshaler, err error) {
+			params := p.(*GetSecurityQuotesParams)
+			req, resp, err = NewGetSecurityQuotes(params.Securities)
+			return
+		},
+	})
+	proto.DefaultRegistry.MustRegister(proto.Command{
+		Name:   "GetIndexBars",
+		ID:     CmdGetIndexBars,
+		Params: func() interface{} { return new(GetIndexBarsParams) },
+		Build: func(p interface{}) (req proto.Marshaler, resp proto.Unmarshaler, err error) {
+			params := p.(*GetIndexBarsParams)
+			req, resp, err = NewGetIndexBars(params.Category, params.Market, params.Code, params.Start, params.Count)
+			return
+		},
+	})
+}
@@ -0,0 +1,60 @@
+package v1
+
+import (
+	"github.com/cyclegen-community/tdx-go/proto"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	cases := []struct {
+		name   string
+		params string
+		build  proto.Factory
+	}{
+		{"SetupCmd1", "", func() (req proto.Marshaler, resp proto.Unmarshaler, err error) {
+			req, resp, err = NewSetupCmd1()
+			return
+		}},
+		{"GetSecurityCount", `{"market": 1}`, func() (req proto.Marshaler, resp proto.Unmarshaler, err error) {
+			req, resp, err = NewGetSecurityCount(MarketShangHai)
+			return
+		}},
+		{"GetSecurityList", `{"market": 0, "start": 1000}`, func() (req proto.Marshaler, resp proto.Unmarshaler, err error) {
+			req, resp, err = NewGetSecurityList(MarketShenZhen, 1000)
+			return
+		}},
+		{"GetIndexBars", `{"category": 9, "market": 1, "code": "000001", "count": 1}`, func() (req proto.Marshaler, resp proto.Unmarshaler, err error) {
+			req, resp, err = NewGetIndexBars(KLineDay, MarketShangHai, "000001", 0, 1)
+			return
+		}},
+		{"GetSecurityQuotes", `{"securities": [{"market": 1, "code": "600300"}]}`, func() (req proto.Marshaler, resp proto.Unmarshaler, err error) {
+			req, resp, err = NewGetSecurityQuotes([]Security{{MarketShangHai, "600300"}})
+			return
+		}},
+	}
+	for _, c := range cases {
+		req, resp, err := proto.DefaultRegistry.Build(c.name, []byte(c.params))
+		if !assert.NoError(t, err, c.name) {
+			continue
+		}
+		wantReq, wantResp, _ := c.build()
+		assert.Equal(t, wantReq, req, c.name)
+		assert.IsType(t, wantResp, resp, c.name)
+		assert.Equal(t, c.name, proto.CommandName(req))
+	}
+
+	// 每个已注册指令的名称与请求的指令名称、指令号一致
+	for _, cmd := range proto.DefaultRegistry.Commands() {
+		req, _, err := proto.DefaultRegistry.Build(cmd.Name, nil)
+		if cmd.Name == "GetSecurityQuotes" || cmd.Name == "GetIndexBars" {
+			// 行情与K线查询的数量不能为0
+			assert.Error(t, err)
+			continue
+		}
+		if assert.NoError(t, err, cmd.Name) {
+			assert.Equal(t, cmd.Name, proto.CommandName(req))
+			assert.Equal(t, cmd.ID, req.(proto.Request).RequestHeader().Command)
+		}
+	}
+}
@@ -2,17 +2,32 @@ package v1
 
 import (
 	"github.com/cyclegen-community/tdx-go/proto"
-	"github.com/cyclegen-community/tdx-go/utils"
 )
 
 // 请求包结构
 type SetupCmd1Request struct {
-	Cmd []byte `struc:"[13]byte" json:"cmd"`
+	Header  proto.RequestHeader `json:"header"`
+	Unknown uint8               `json:"unknown"`
 }
 
 // 请求包序列化输出
 func (req *SetupCmd1Request) Marshal() ([]byte, error) {
-	return proto.DefaultMarshal(req)
+	return proto.MarshalRequest(&req.Header, req)
+}
+
+// 请求包体布局
+func (req *SetupCmd1Request) MarshalBody(w *proto.Writer) {
+	w.Uint8(req.Unknown)
+}
+
+// 请求包头
+func (req *SetupCmd1Request) RequestHeader() *proto.RequestHeader {
+	return &req.Header
+}
+
+// 指令名称
+func (req *SetupCmd1Request) Command() string {
+	return "SetupCmd1"
 }
 
 // 响应包结构
@@ -21,14 +36,20 @@ type SetupCmd1Response struct {
 }
 
 func (resp *SetupCmd1Response) Unmarshal(data []byte) error {
-	resp.Unknown = data
+	resp.Unknown = append([]byte(nil), data...)
 	return nil
 }
 
+// 解析结果不引用响应数据，可直接解析缓冲池中的数据
+func (resp *SetupCmd1Response) BufferSafe() bool {
+	return true
+}
+
 // 创建SetupCmd1请求包
 func NewSetupCmd1Request() (*SetupCmd1Request, error) {
 	request := &SetupCmd1Request{
-		Cmd: utils.HexString2Bytes("0c 02 18 93 00 01 03 00 03 00 0d 00 01"),
+		Header:  proto.NewRequestHeader(CmdSetupCmd1),
+		Unknown: 0x01,
 	}
 	return request, nil
 }
@@ -7,12 +7,28 @@ import (
 
 // 请求包结构
 type SetupCmd3Request struct {
-	Cmd []byte `struc:"[42]byte" json:"cmd"`
+	Header  proto.RequestHeader `json:"header"`
+	Unknown []byte              `json:"unknown"`
 }
 
 // 请求包序列化输出
 func (req *SetupCmd3Request) Marshal() ([]byte, error) {
-	return proto.DefaultMarshal(req)
+	return proto.MarshalRequest(&req.Header, req)
+}
+
+// 请求包体布局
+func (req *SetupCmd3Request) MarshalBody(w *proto.Writer) {
+	w.Fixed(req.Unknown, 30)
+}
+
+// 请求包头
+func (req *SetupCmd3Request) RequestHeader() *proto.RequestHeader {
+	return &req.Header
+}
+
+// 指令名称
+func (req *SetupCmd3Request) Command() string {
+	return "SetupCmd3"
 }
 
 // 响应包结构
@@ -21,17 +37,21 @@ type SetupCmd3Response struct {
 }
 
 func (resp *SetupCmd3Response) Unmarshal(data []byte) error {
-	resp.Unknown = data
+	resp.Unknown = append([]byte(nil), data...)
 	return nil
 }
 
+// 解析结果不引用响应数据，可直接解析缓冲池中的数据
+func (resp *SetupCmd3Response) BufferSafe() bool {
+	return true
+}
+
 // 创建SetupCmd3请求包
 func NewSetupCmd3Request() (*SetupCmd3Request, error) {
 	request := &SetupCmd3Request{
-		Cmd: utils.HexString2Bytes("0c 03 18 99 00 01 20 00 20 00 db 0f d5" +
-			"d0 c9 cc d6 a4 a8 af 00 00 00 8f c2 25" +
-			"40 13 00 00 d5 00 c9 cc bd f0 d7 ea 00" +
-			"00 00 02"),
+		Header: proto.NewRequestHeader(CmdSetupCmd3),
+		Unknown: utils.HexString2Bytes("d5 d0 c9 cc d6 a4 a8 af 00 00 00 8f c2 25" +
+			"40 13 00 00 d5 00 c9 cc bd f0 d7 ea 00 00 00 02"),
 	}
 	return request, nil
 }
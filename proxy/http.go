@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// HTTPConnect 通过HTTP代理的CONNECT方法建立隧道，Username非空时使用Basic认证
+type HTTPConnect struct {
+	Addr     string
+	Username string
+	Password string
+	// Header 附加到CONNECT请求的头部
+	Header http.Header
+	// Forward 用于连接代理服务器，为nil时直连
+	Forward Dialer
+}
+
+func (h *HTTPConnect) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if network != "tcp" && network != "tcp4" && network != "tcp6" {
+		return nil, fmt.Errorf("%w: http %s", ErrUnsupported, network)
+	}
+	conn, err := dialProxy(ctx, h.Forward, h.Addr, func(conn net.Conn) (net.Conn, error) {
+		return h.handshake(conn, addr)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("http代理%s连接%s: %w", h.Addr, addr, err)
+	}
+	return conn, nil
+}
+
+func (h *HTTPConnect) handshake(conn net.Conn, addr string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	for key, values := range h.Header {
+		req.Header[key] = values
+	}
+	if h.Username != "" {
+		token := base64.StdEncoding.EncodeToString([]byte(h.Username + ":" + h.Password))
+		req.Header.Set("Proxy-Authorization", "Basic "+token)
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusProxyAuthRequired:
+		return nil, ErrAuth
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%w: %s", ErrRejected, resp.Status)
+	}
+	// 代理可能在应答之后紧接着转发了目标服务器的数据
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn 先读取已缓冲的数据，再从连接读取
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
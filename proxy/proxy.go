@@ -0,0 +1,92 @@
+package proxy
+
+// 通过SOCKS5或HTTP CONNECT代理连接行情服务器
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+)
+
+var (
+	// ErrAuth 代理拒绝了提供的认证信息，或要求认证而未提供
+	ErrAuth = errors.New("代理认证失败")
+	// ErrRejected 代理拒绝建立到目标地址的连接
+	ErrRejected = errors.New("代理拒绝连接")
+	// ErrUnsupported 不支持的代理协议
+	ErrUnsupported = errors.New("不支持的代理协议")
+)
+
+// Dialer 建立TCP连接，方法签名与net.Dialer.DialContext一致，可直接用于core.WithDialContext
+type Dialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// Direct 不经代理直接连接
+var Direct Dialer = &net.Dialer{}
+
+// Parse 解析代理地址
+// 支持socks5://[user:pass@]host:port与http://[user:pass@]host:port，空字符串表示直连
+func Parse(raw string) (Dialer, error) {
+	if raw == "" {
+		return Direct, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	return FromURL(u, Direct)
+}
+
+// FromURL 根据代理地址创建Dialer，forward用于连接代理服务器本身
+func FromURL(u *url.URL, forward Dialer) (Dialer, error) {
+	var username, password string
+	if u.User != nil {
+		username = u.User.Username()
+		password, _ = u.User.Password()
+	}
+	switch u.Scheme {
+	case "socks5", "socks5h":
+		return &SOCKS5{Addr: u.Host, Username: username, Password: password, Forward: forward}, nil
+	case "http":
+		return &HTTPConnect{Addr: u.Host, Username: username, Password: password, Forward: forward}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupported, u.Scheme)
+}
+
+// dialProxy 连接代理服务器并在ctx约束下完成握手，握手失败时关闭连接
+func dialProxy(ctx context.Context, forward Dialer, proxyAddr string, handshake func(conn net.Conn) (net.Conn, error)) (net.Conn, error) {
+	if forward == nil {
+		forward = Direct
+	}
+	conn, err := forward.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+	// ctx结束时使阻塞中的读写立即返回，截止时间同样经由ctx.Done生效，保证返回ctx的错误
+	quit := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Unix(1, 0))
+		case <-quit:
+		}
+	}()
+	tunnel, err := handshake(conn)
+	close(quit)
+	<-exited
+	// 因ctx结束而中断时返回ctx的错误
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return tunnel, nil
+}
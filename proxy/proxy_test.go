@@ -0,0 +1,209 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// listen 在本地端口上为每个连接运行handle
+func listen(t *testing.T, handle func(conn net.Conn)) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// echoServer 目标服务器替身，原样返回收到的数据
+func echoServer(t *testing.T) string {
+	return listen(t, func(conn net.Conn) {
+		io.Copy(conn, conn)
+	})
+}
+
+// relay 将连接与目标地址双向转发
+func relay(conn net.Conn, addr string) {
+	target, err := net.Dial("tcp", addr)
+	if err != nil {
+		return
+	}
+	defer target.Close()
+	go io.Copy(target, conn)
+	io.Copy(conn, target)
+}
+
+// socks5Server SOCKS5代理替身，username非空时要求用户名密码认证
+func socks5Server(t *testing.T, username, password string) string {
+	return listen(t, func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+		head := make([]byte, 2)
+		io.ReadFull(r, head)
+		methods := make([]byte, head[1])
+		io.ReadFull(r, methods)
+		if username == "" {
+			conn.Write([]byte{5, 0})
+		} else {
+			conn.Write([]byte{5, 2})
+			ver, _ := r.ReadByte()
+			n, _ := r.ReadByte()
+			user := make([]byte, n)
+			io.ReadFull(r, user)
+			n, _ = r.ReadByte()
+			pass := make([]byte, n)
+			io.ReadFull(r, pass)
+			if ver != 1 || string(user) != username || string(pass) != password {
+				conn.Write([]byte{1, 1})
+				return
+			}
+			conn.Write([]byte{1, 0})
+		}
+		req := make([]byte, 4)
+		io.ReadFull(r, req)
+		var host string
+		switch req[3] {
+		case 1:
+			ip := make([]byte, 4)
+			io.ReadFull(r, ip)
+			host = net.IP(ip).String()
+		case 3:
+			n, _ := r.ReadByte()
+			name := make([]byte, n)
+			io.ReadFull(r, name)
+			host = string(name)
+		}
+		port := make([]byte, 2)
+		io.ReadFull(r, port)
+		addr := net.JoinHostPort(host, strconv.Itoa(int(port[0])<<8|int(port[1])))
+		if host == "refused.invalid" {
+			conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+			return
+		}
+		conn.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 0})
+		relay(conn, addr)
+	})
+}
+
+// httpProxyServer HTTP CONNECT代理替身，username非空时要求Basic认证
+func httpProxyServer(t *testing.T, username, password string) string {
+	return listen(t, func(conn net.Conn) {
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil || req.Method != http.MethodConnect {
+			return
+		}
+		if username != "" {
+			token := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+			if req.Header.Get("Proxy-Authorization") != "Basic "+token {
+				fmt.Fprint(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+				return
+			}
+		}
+		fmt.Fprint(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+		relay(conn, req.Host)
+	})
+}
+
+// assertEcho 确认经由dialer建立的连接可以正常收发数据
+func assertEcho(t *testing.T, dialer Dialer, addr string) {
+	conn, err := dialer.DialContext(context.Background(), "tcp", addr)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
+	_, err = conn.Write([]byte("ping"))
+	assert.NoError(t, err)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(conn, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "ping", string(buf))
+}
+
+func TestSOCKS5(t *testing.T) {
+	target := echoServer(t)
+
+	dialer, err := Parse("socks5://" + socks5Server(t, "", ""))
+	assert.NoError(t, err)
+	assertEcho(t, dialer, target)
+
+	// 目标主机名交由代理解析
+	_, port, _ := net.SplitHostPort(target)
+	assertEcho(t, dialer, net.JoinHostPort("localhost", port))
+
+	_, err = dialer.DialContext(context.Background(), "tcp", "refused.invalid:7709")
+	assert.True(t, errors.Is(err, ErrRejected))
+
+	authAddr := socks5Server(t, "user", "secret")
+	dialer, _ = Parse("socks5://user:secret@" + authAddr)
+	assertEcho(t, dialer, target)
+
+	dialer, _ = Parse("socks5://user:wrong@" + authAddr)
+	_, err = dialer.DialContext(context.Background(), "tcp", target)
+	assert.True(t, errors.Is(err, ErrAuth))
+
+	dialer, _ = Parse("socks5://" + authAddr)
+	_, err = dialer.DialContext(context.Background(), "tcp", target)
+	assert.True(t, errors.Is(err, ErrAuth))
+}
+
+func TestHTTPConnect(t *testing.T) {
+	target := echoServer(t)
+
+	dialer, err := Parse("http://" + httpProxyServer(t, "", ""))
+	assert.NoError(t, err)
+	assertEcho(t, dialer, target)
+
+	authAddr := httpProxyServer(t, "user", "secret")
+	dialer, _ = Parse("http://user:secret@" + authAddr)
+	assertEcho(t, dialer, target)
+
+	dialer, _ = Parse("http://user:wrong@" + authAddr)
+	_, err = dialer.DialContext(context.Background(), "tcp", target)
+	assert.True(t, errors.Is(err, ErrAuth))
+}
+
+func TestDialContextCancel(t *testing.T) {
+	// 代理接受连接后不作任何应答
+	stall := listen(t, func(conn net.Conn) {
+		io.Copy(ioutil.Discard, conn)
+	})
+	for _, raw := range []string{"socks5://" + stall, "http://" + stall} {
+		dialer, _ := Parse(raw)
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		_, err := dialer.DialContext(ctx, "tcp", "127.0.0.1:7709")
+		cancel()
+		assert.True(t, errors.Is(err, context.DeadlineExceeded), raw)
+	}
+}
+
+func TestParse(t *testing.T) {
+	dialer, err := Parse("")
+	assert.NoError(t, err)
+	assert.Equal(t, Direct, dialer)
+
+	_, err = Parse("https://proxy:443")
+	assert.True(t, errors.Is(err, ErrUnsupported))
+}
@@ -0,0 +1,172 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+// SOCKS5协议常量，见RFC 1928与RFC 1929
+const (
+	socks5Version       = 0x05
+	socks5AuthNone      = 0x00
+	socks5AuthPassword  = 0x02
+	socks5NoAcceptable  = 0xff
+	socks5Connect       = 0x01
+	socks5AtypIPv4      = 0x01
+	socks5AtypDomain    = 0x03
+	socks5AtypIPv6      = 0x04
+	socks5PasswordAuthV = 0x01
+)
+
+var socks5Replies = []string{
+	"成功",
+	"代理服务器故障",
+	"规则不允许连接",
+	"网络不可达",
+	"主机不可达",
+	"连接被拒绝",
+	"TTL过期",
+	"不支持的命令",
+	"不支持的地址类型",
+}
+
+// SOCKS5 通过SOCKS5代理建立连接，Username非空时使用用户名密码认证
+// 目标主机名交由代理解析
+type SOCKS5 struct {
+	Addr     string
+	Username string
+	Password string
+	// Forward 用于连接代理服务器，为nil时直连
+	Forward Dialer
+}
+
+func (s *SOCKS5) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if network != "tcp" && network != "tcp4" && network != "tcp6" {
+		return nil, fmt.Errorf("%w: socks5 %s", ErrUnsupported, network)
+	}
+	conn, err := dialProxy(ctx, s.Forward, s.Addr, func(conn net.Conn) (net.Conn, error) {
+		return conn, s.handshake(conn, addr)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("socks5代理%s连接%s: %w", s.Addr, addr, err)
+	}
+	return conn, nil
+}
+
+func (s *SOCKS5) handshake(conn net.Conn, addr string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return fmt.Errorf("端口%q无效", portStr)
+	}
+
+	// 协商认证方式
+	methods := []byte{socks5Version, 1, socks5AuthNone}
+	if s.Username != "" {
+		methods = []byte{socks5Version, 2, socks5AuthNone, socks5AuthPassword}
+	}
+	if _, err := conn.Write(methods); err != nil {
+		return err
+	}
+	var reply [2]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return err
+	}
+	if reply[0] != socks5Version {
+		return fmt.Errorf("协议版本%d无效", reply[0])
+	}
+	switch reply[1] {
+	case socks5AuthNone:
+	case socks5AuthPassword:
+		if s.Username == "" {
+			return ErrAuth
+		}
+		if err := s.authenticate(conn); err != nil {
+			return err
+		}
+	case socks5NoAcceptable:
+		return ErrAuth
+	default:
+		return fmt.Errorf("%w: 认证方式%d", ErrUnsupported, reply[1])
+	}
+
+	// 请求连接目标地址
+	req := []byte{socks5Version, socks5Connect, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return fmt.Errorf("主机名%q过长", host)
+		}
+		req = append(req, socks5AtypDomain, byte(len(host)))
+		req = append(req, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		req = append(req, socks5AtypIPv4)
+		req = append(req, ip4...)
+	} else {
+		req = append(req, socks5AtypIPv6)
+		req = append(req, ip.To16()...)
+	}
+	req = append(req, byte(port>>8), byte(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	// 应答: VER REP RSV ATYP BND.ADDR BND.PORT
+	var head [4]byte
+	if _, err := io.ReadFull(conn, head[:]); err != nil {
+		return err
+	}
+	if head[1] != 0 {
+		msg := "未知错误"
+		if int(head[1]) < len(socks5Replies) {
+			msg = socks5Replies[head[1]]
+		}
+		return fmt.Errorf("%w: %s", ErrRejected, msg)
+	}
+	var skip int
+	switch head[3] {
+	case socks5AtypIPv4:
+		skip = net.IPv4len
+	case socks5AtypIPv6:
+		skip = net.IPv6len
+	case socks5AtypDomain:
+		var n [1]byte
+		if _, err := io.ReadFull(conn, n[:]); err != nil {
+			return err
+		}
+		skip = int(n[0])
+	default:
+		return fmt.Errorf("地址类型%d无效", head[3])
+	}
+	// 代理绑定的地址与端口对客户端无用，读出后丢弃
+	bound := make([]byte, skip+2)
+	_, err = io.ReadFull(conn, bound)
+	return err
+}
+
+// authenticate 用户名密码认证，见RFC 1929
+func (s *SOCKS5) authenticate(conn net.Conn) error {
+	if len(s.Username) > 255 || len(s.Password) > 255 {
+		return fmt.Errorf("%w: 用户名或密码过长", ErrAuth)
+	}
+	req := []byte{socks5PasswordAuthV, byte(len(s.Username))}
+	req = append(req, s.Username...)
+	req = append(req, byte(len(s.Password)))
+	req = append(req, s.Password...)
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+	var reply [2]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return err
+	}
+	if reply[1] != 0 {
+		return ErrAuth
+	}
+	return nil
+}
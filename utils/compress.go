@@ -2,8 +2,13 @@ package utils
 
 import (
 	"bytes"
+	"compress/flate"
 	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/adler32"
 	"io"
+	"sync"
 )
 
 //进行zlib压缩
@@ -21,17 +26,97 @@ func ZlibCompress(src []byte) ([]byte, error) {
 	return in.Bytes(), nil
 }
 
+// inflater 可复用的zlib解压器，避免每次解压都重新分配内部的窗口与缓冲区
+// 自行解析zlib头并校验adler32，因为zlib.Reader每次Reset都会重新分配校验和计算器
+type inflater struct {
+	src     bytes.Reader
+	fr      io.ReadCloser
+	scratch [4]byte
+}
+
+var inflaters = sync.Pool{
+	New: func() interface{} {
+		return new(inflater)
+	},
+}
+
+// reset 校验zlib头并使解压器从其后的deflate数据开始读取
+func (f *inflater) reset(src []byte) error {
+	// 2字节头部与4字节adler32校验和
+	if len(src) < 6 {
+		return io.ErrUnexpectedEOF
+	}
+	cmf, flg := src[0], src[1]
+	if cmf&0x0f != 8 || (uint16(cmf)<<8|uint16(flg))%31 != 0 {
+		return zlib.ErrHeader
+	}
+	if flg&0x20 != 0 {
+		return zlib.ErrDictionary
+	}
+	f.src.Reset(src[2:])
+	if f.fr == nil {
+		f.fr = flate.NewReader(&f.src)
+		return nil
+	}
+	return f.fr.(flate.Resetter).Reset(&f.src, nil)
+}
+
+// verify 在deflate数据读完后校验紧随其后的adler32
+func (f *inflater) verify(data []byte) error {
+	if _, err := io.ReadFull(&f.src, f.scratch[:]); err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	if binary.BigEndian.Uint32(f.scratch[:]) != adler32.Checksum(data) {
+		return zlib.ErrChecksum
+	}
+	return nil
+}
+
 //进行zlib解压缩
 func ZlibUnCompress(compressSrc []byte) ([]byte, error) {
-	b := bytes.NewReader(compressSrc)
+	f := inflaters.Get().(*inflater)
+	defer inflaters.Put(f)
+	if err := f.reset(compressSrc); err != nil {
+		return nil, err
+	}
 	var out bytes.Buffer
-	r, err := zlib.NewReader(b)
+	_, err := io.Copy(&out, f.fr)
 	if err != nil {
 		return nil, err
 	}
-	_, err = io.Copy(&out, r)
-	if err != nil {
+	if err := f.verify(out.Bytes()); err != nil {
 		return nil, err
 	}
 	return out.Bytes(), nil
 }
+
+// ZlibUnCompressTo 将compressSrc解压到dst中，解压后的长度必须恰好为len(dst)
+// 最多只解压len(dst)+1字节，避免异常数据耗尽内存；解压器取自对象池，解压过程不分配内存
+func ZlibUnCompressTo(dst, compressSrc []byte) error {
+	f := inflaters.Get().(*inflater)
+	defer inflaters.Put(f)
+	if err := f.reset(compressSrc); err != nil {
+		return err
+	}
+	n, err := io.ReadFull(f.fr, dst)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("解压后长度%d与预期%d不符", n, len(dst))
+	}
+	if err != nil {
+		return err
+	}
+	// 确认数据恰好在此结束
+	for {
+		n, err := f.fr.Read(f.scratch[:1])
+		if n > 0 {
+			return fmt.Errorf("解压后长度超过%d", len(dst))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return f.verify(dst)
+}
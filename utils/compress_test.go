@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestZlibUnCompressTo(t *testing.T) {
+	plain := bytes.Repeat([]byte("tdx-go"), 100)
+	zipped, err := ZlibCompress(plain)
+	assert.NoError(t, err)
+
+	out := make([]byte, len(plain))
+	assert.NoError(t, ZlibUnCompressTo(out, zipped))
+	assert.Equal(t, plain, out)
+	// 解压后的长度与预期不符
+	assert.Error(t, ZlibUnCompressTo(make([]byte, len(plain)-1), zipped))
+	assert.Error(t, ZlibUnCompressTo(make([]byte, len(plain)+1), zipped))
+	// 数据被截断
+	assert.Error(t, ZlibUnCompressTo(out, zipped[:len(zipped)/2]))
+	// 校验和错误
+	corrupted := append([]byte(nil), zipped...)
+	corrupted[len(corrupted)-1]++
+	assert.Error(t, ZlibUnCompressTo(out, corrupted))
+	// 复用解压器后结果仍正确
+	full, err := ZlibUnCompress(zipped)
+	assert.NoError(t, err)
+	assert.Equal(t, plain, full)
+}
+
+func benchmarkZlibData() ([]byte, int) {
+	plain := bytes.Repeat([]byte("tdx-go"), 1000)
+	zipped, _ := ZlibCompress(plain)
+	return zipped, len(plain)
+}
+
+func BenchmarkZlibUnCompress(b *testing.B) {
+	zipped, _ := benchmarkZlibData()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := ZlibUnCompress(zipped); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkZlibUnCompressTo(b *testing.B) {
+	zipped, size := benchmarkZlibData()
+	out := make([]byte, size)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := ZlibUnCompressTo(out, zipped); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
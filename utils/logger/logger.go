@@ -0,0 +1,88 @@
+package logger
+
+import (
+	"fmt"
+	"log"
+)
+
+// Level 日志级别
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	}
+	return "UNKNOWN"
+}
+
+// Logger 分级日志接口，可接入任意日志库
+type Logger interface {
+	Debugf(format string, v ...interface{})
+	Infof(format string, v ...interface{})
+	Warnf(format string, v ...interface{})
+	Errorf(format string, v ...interface{})
+}
+
+// Nop 丢弃所有日志，作为各组件的默认值
+var Nop Logger = nop{}
+
+type nop struct{}
+
+func (nop) Debugf(format string, v ...interface{}) {}
+func (nop) Infof(format string, v ...interface{})  {}
+func (nop) Warnf(format string, v ...interface{})  {}
+func (nop) Errorf(format string, v ...interface{}) {}
+
+// New 基于标准库log.Logger创建Logger，仅输出不低于level的日志
+// l为nil时使用log包的标准输出
+func New(l *log.Logger, level Level) Logger {
+	return &stdLogger{l: l, level: level}
+}
+
+type stdLogger struct {
+	l     *log.Logger
+	level Level
+}
+
+func (s *stdLogger) output(level Level, format string, v ...interface{}) {
+	if level < s.level {
+		return
+	}
+	msg := "[" + level.String() + "] " + fmt.Sprintf(format, v...)
+	// 跳过output与Xxxf两层调用，使Lshortfile指向调用方
+	if s.l != nil {
+		s.l.Output(3, msg)
+	} else {
+		log.Output(3, msg)
+	}
+}
+
+func (s *stdLogger) Debugf(format string, v ...interface{}) {
+	s.output(LevelDebug, format, v...)
+}
+
+func (s *stdLogger) Infof(format string, v ...interface{}) {
+	s.output(LevelInfo, format, v...)
+}
+
+func (s *stdLogger) Warnf(format string, v ...interface{}) {
+	s.output(LevelWarn, format, v...)
+}
+
+func (s *stdLogger) Errorf(format string, v ...interface{}) {
+	s.output(LevelError, format, v...)
+}